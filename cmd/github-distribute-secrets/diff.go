@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/drift"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+// errDrift is returned by the diff command if a repository differs from the
+// configuration.
+var errDrift = errors.New("repository secrets drifted from the configuration")
+
+func runDiff(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, gh github.GithubClient, format string, out io.Writer) error {
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("unknown output format %s, expected %s or %s", format, formatText, formatJSON)
+	}
+
+	configuration, err := configFileReader.ReadConfiguration(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if err = configuration.ExpandPatterns(func(owner string) ([]string, error) {
+		return gh.ListRepositories(ctx, owner)
+	}); err != nil {
+		return fmt.Errorf("failed to expand repository patterns: %w", err)
+	}
+
+	reports := make([]drift.Report, 0, len(configuration.Repositories))
+	for _, repository := range configuration.Repositories {
+		existing, err := gh.ListSecrets(ctx, repository)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, drift.Compare(repository, drift.ConfiguredSecrets(configuration, repository), existing))
+	}
+
+	if format == formatJSON {
+		err = drift.WriteJSON(out, reports)
+	} else {
+		err = drift.WriteText(out, reports)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write the differences: %w", err)
+	}
+
+	if drift.HasDrift(reports) {
+		return errDrift
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+func TestRunDiff(t *testing.T) {
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("should report the differences of every repository", func(t *testing.T) {
+		var out bytes.Buffer
+		githubClient := &github.MockGithubClient{Secrets: map[string][]github.Secret{
+			"owner/a": {{Name: "API_KEY", UpdatedAt: updatedAt}, {Name: "REMOVED", UpdatedAt: updatedAt}},
+		}}
+
+		err := runDiff(context.Background(), createConfigFileReader(t, "owner/a:\n  API_KEY: op://vault/api/key\n  NEW_KEY: op://vault/new/key\n"), testConfigPath, githubClient, formatText, &out)
+
+		assert.ErrorIs(t, err, errDrift)
+		assert.Contains(t, out.String(), "missing  NEW_KEY")
+		assert.Contains(t, out.String(), "extra    REMOVED")
+		assert.Contains(t, out.String(), "present  API_KEY")
+	})
+
+	t.Run("should not return an error if nothing drifted", func(t *testing.T) {
+		var out bytes.Buffer
+		githubClient := &github.MockGithubClient{Secrets: map[string][]github.Secret{
+			"owner/a": {{Name: "API_KEY", UpdatedAt: updatedAt}},
+		}}
+
+		err := runDiff(context.Background(), createConfigFileReader(t, "owner/a:\n  API_KEY: op://vault/api/key\n"), testConfigPath, githubClient, formatJSON, &out)
+
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), `"drift": false`)
+	})
+
+	t.Run("should compare the repositories matching a pattern", func(t *testing.T) {
+		var out bytes.Buffer
+		githubClient := &github.MockGithubClient{Repositories: []string{"owner/a-gcp"}}
+
+		err := runDiff(context.Background(), createConfigFileReader(t, "owner/*-gcp:\n  API_KEY: op://vault/api/key\n"), testConfigPath, githubClient, formatText, &out)
+
+		assert.ErrorIs(t, err, errDrift)
+		assert.Contains(t, out.String(), "owner/a-gcp:\n  missing  API_KEY")
+	})
+
+	t.Run("should return an error if the secrets cannot be listed", func(t *testing.T) {
+		githubClient := &github.MockGithubClient{ListSecretsError: assert.AnError}
+
+		err := runDiff(context.Background(), createConfigFileReader(t, "owner/a:\n  API_KEY: op://vault/api/key\n"), testConfigPath, githubClient, formatText, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("should return an error for unknown formats", func(t *testing.T) {
+		err := runDiff(context.Background(), createConfigFileReader(t, "owner/a:\n"), testConfigPath, &github.MockGithubClient{}, "xml", &bytes.Buffer{})
+
+		assert.ErrorContains(t, err, "unknown output format xml")
+	})
+
+	t.Run("should return the error if reading the config failed", func(t *testing.T) {
+		err := runDiff(context.Background(), &MockConfigFileReader{expectedError: assert.AnError}, testConfigPath, &github.MockGithubClient{}, formatText, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
@@ -1,44 +1,186 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 
 	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/drift"
 	"koenighotze.de/github-distribute-secrets/pkg/github"
-	"koenighotze.de/github-distribute-secrets/pkg/onepassword"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
 )
 
-func githubSecretDistribution(configFileReader config.ConfigFileReader, op onepassword.OnePasswordClient, gh github.GithubClient, dumpConfig bool) error {
-	configuration, err := configFileReader.ReadConfiguration("./config.yml")
+func githubSecretDistribution(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+	configuration, err := configFileReader.ReadConfiguration(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
+	if err = configuration.ExpandPatterns(func(owner string) ([]string, error) {
+		return gh.ListRepositories(ctx, owner)
+	}); err != nil {
+		return fmt.Errorf("failed to expand repository patterns: %w", err)
+	}
+
 	if dumpConfig {
 		fmt.Println(configuration.DumpConfiguration())
 	}
 
-	if !applyConfiguration(configuration, op, gh) {
+	prefetchSecrets(ctx, configuration, secrets)
+
+	ok := applyConfiguration(ctx, configuration, secrets, gh, prune)
+	if err = ctx.Err(); err != nil {
+		return fmt.Errorf("distribution was interrupted: %w", err)
+	}
+	if !ok {
 		return fmt.Errorf("configuration was not applied successfully")
 	}
 
 	return nil
 }
 
-func applyConfigurationToRepository(configMap config.RepositoryConfiguration, repository string, op onepassword.OnePasswordClient, gh github.GithubClient) (ok bool) {
+// prefetchSecrets reads every reference of the configuration up front if the
+// provider supports it. A failed prefetch is not fatal, the secrets are then
+// read one by one while applying the configuration.
+func prefetchSecrets(ctx context.Context, configuration *config.Configuration, secrets provider.Provider) {
+	prefetcher, ok := secrets.(provider.Prefetcher)
+	if !ok {
+		return
+	}
+
+	if err := prefetcher.Prefetch(ctx, configuration.GetAllReferences()); err != nil {
+		log.Printf("Reading the secrets in one batch failed, reading them one by one: %v", err)
+	}
+}
+
+func applyConfigurationToRepository(ctx context.Context, configMap config.RepositoryConfiguration, apps config.SecretApps, repository string, secrets provider.Provider, gh github.GithubClient) (ok bool) {
 	ok = true
 
-	for key, onePasswordPath := range configMap {
-		secret, err := op.GetSecret(onePasswordPath)
+	for key, reference := range configMap {
+		secret, err := secrets.GetSecret(ctx, reference)
 		if err != nil {
 			log.Printf("Error reading secret %s: %v", key, err)
 			ok = false
 			continue
 		}
 
-		if err = gh.AddSecretToRepository(key, secret, repository); err != nil {
-			log.Printf("Error adding secret with key %s to repository %s: %v", key, repository, err)
+		targetApps, found := apps[key]
+		if !found {
+			targetApps = []string{config.AppActions}
+		}
+
+		for _, app := range targetApps {
+			if app == config.AppActions {
+				err = gh.AddSecretToRepository(ctx, key, secret, repository)
+			} else {
+				err = gh.AddSecretToApp(ctx, key, secret, app, repository)
+			}
+			if err != nil {
+				log.Printf("Error adding %s secret with key %s to repository %s: %v", app, key, repository, err)
+				ok = false
+			}
+		}
+	}
+
+	return ok
+}
+
+func applyEnvironmentsToRepository(ctx context.Context, environments config.EnvironmentConfiguration, repository string, secrets provider.Provider, gh github.GithubClient) (ok bool) {
+	ok = true
+
+	for environment, configMap := range environments {
+		for key, reference := range configMap {
+			secret, err := secrets.GetSecret(ctx, reference)
+			if err != nil {
+				log.Printf("Error reading secret %s for environment %s: %v", key, environment, err)
+				ok = false
+				continue
+			}
+
+			if err = gh.AddSecretToEnvironment(ctx, key, secret, environment, repository); err != nil {
+				log.Printf("Error adding secret with key %s to environment %s of repository %s: %v", key, environment, repository, err)
+				ok = false
+			}
+		}
+	}
+
+	return ok
+}
+
+func applyVariablesToRepository(ctx context.Context, variables config.RepositoryConfiguration, repository string, secrets provider.Provider, gh github.GithubClient) (ok bool) {
+	ok = true
+
+	for name, value := range variables {
+		if provider.IsReference(value) {
+			resolved, err := secrets.GetSecret(ctx, value)
+			if err != nil {
+				log.Printf("Error reading value of variable %s: %v", name, err)
+				ok = false
+				continue
+			}
+			value = resolved
+		}
+
+		if err := gh.AddVariableToRepository(ctx, name, value, repository); err != nil {
+			log.Printf("Error adding variable %s to repository %s: %v", name, repository, err)
+			ok = false
+		}
+	}
+
+	return ok
+}
+
+func applyOrganizations(ctx context.Context, configuration *config.Configuration, secrets provider.Provider, gh github.GithubClient) (ok bool) {
+	ok = true
+
+	for organization, orgConfig := range configuration.Organizations {
+		var repositories []string
+		if orgConfig.Visibility == config.VisibilitySelected {
+			repositories = configuration.GetSelectedRepositoriesForOrganization(organization)
+		}
+
+		for key, reference := range orgConfig.Secrets {
+			secret, err := secrets.GetSecret(ctx, reference)
+			if err != nil {
+				log.Printf("Error reading secret %s for organization %s: %v", key, organization, err)
+				ok = false
+				continue
+			}
+
+			if err = gh.AddSecretToOrganization(ctx, key, secret, organization, orgConfig.Visibility, repositories); err != nil {
+				log.Printf("Error adding secret with key %s to organization %s: %v", key, organization, err)
+				ok = false
+			}
+		}
+	}
+
+	return ok
+}
+
+// pruneRepository deletes the Actions secrets of the repository that are not
+// configured, unless the repository switched pruning off or a secret is
+// protected by defaults.never_prune.
+func pruneRepository(ctx context.Context, configuration *config.Configuration, repository string, gh github.GithubClient) (ok bool) {
+	if !configuration.PruneEnabled(repository) {
+		return true
+	}
+
+	existing, err := gh.ListSecrets(ctx, repository)
+	if err != nil {
+		log.Printf("Error listing secrets of repository %s: %v", repository, err)
+		return false
+	}
+
+	ok = true
+	report := drift.Compare(repository, drift.ConfiguredSecrets(configuration, repository), existing)
+	for _, secret := range report.Extra {
+		if configuration.IsNeverPruned(secret.Name) {
+			log.Printf("In repository %s. Keeping unmanaged secret with key %s, it is never pruned", repository, secret.Name)
+			continue
+		}
+		if err = gh.DeleteSecret(ctx, secret.Name, repository); err != nil {
+			log.Printf("Error deleting secret with key %s from repository %s: %v", secret.Name, repository, err)
 			ok = false
 		}
 	}
@@ -46,12 +188,49 @@ func applyConfigurationToRepository(configMap config.RepositoryConfiguration, re
 	return ok
 }
 
-func applyConfiguration(configuration *config.Configuration, op onepassword.OnePasswordClient, gh github.GithubClient) (allOk bool) {
+// applySummary counts the repositories by outcome, so an interrupted run still
+// tells what was completed.
+type applySummary struct {
+	updated      int
+	failed       int
+	notAttempted int
+}
+
+func (s applySummary) String() string {
+	return fmt.Sprintf("%d repositories updated, %d failed, %d not attempted", s.updated, s.failed, s.notAttempted)
+}
+
+// applyConfiguration stops starting new repositories once the context is
+// cancelled. Commands in flight are cancelled through the context as well.
+func applyConfiguration(ctx context.Context, configuration *config.Configuration, secrets provider.Provider, gh github.GithubClient, prune bool) (allOk bool) {
+	var summary applySummary
+	defer func() { log.Printf("Summary: %s", summary) }()
+
 	allOk = true
-	for _, repository := range configuration.Repositories {
-		if ok := applyConfigurationToRepository(configuration.GetConfigurationForRepository(repository), repository, op, gh); !ok {
+	if ok := applyOrganizations(ctx, configuration, secrets, gh); !ok {
+		log.Println("Cannot apply config to organizations successfully!")
+		allOk = false
+	}
+
+	for index, repository := range configuration.Repositories {
+		if ctx.Err() != nil {
+			summary.notAttempted = len(configuration.Repositories) - index
+			log.Printf("Interrupted, not applying config to the remaining %d repositories", summary.notAttempted)
+			return false
+		}
+
+		ok := applyConfigurationToRepository(ctx, configuration.GetConfigurationForRepository(repository), configuration.GetAppsForRepository(repository), repository, secrets, gh)
+		ok = applyEnvironmentsToRepository(ctx, configuration.GetEnvironmentsForRepository(repository), repository, secrets, gh) && ok
+		ok = applyVariablesToRepository(ctx, configuration.GetVariablesForRepository(repository), repository, secrets, gh) && ok
+		if prune {
+			ok = pruneRepository(ctx, configuration, repository, gh) && ok
+		}
+		if !ok {
 			log.Printf("Cannot apply config to repository %s successfully!", repository)
+			summary.failed++
 			allOk = false
+		} else {
+			summary.updated++
 		}
 	}
 	return
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/lint"
+)
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+func runLint(configFileReader config.ConfigFileReader, configPath string, format string, out io.Writer) error {
+	if format != formatText && format != formatJSON {
+		return fmt.Errorf("unknown output format %s, expected %s or %s", format, formatText, formatJSON)
+	}
+
+	configuration, err := configFileReader.ReadConfiguration(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	findings := lint.Run(configuration, lint.DefaultRules())
+
+	if format == formatJSON {
+		err = lint.WriteJSON(out, findings)
+	} else {
+		err = lint.WriteText(out, findings)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write findings: %w", err)
+	}
+
+	if lint.HasErrors(findings) {
+		return fmt.Errorf("configuration has lint errors")
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunLint(t *testing.T) {
+
+	t.Run("should write the findings as text", func(t *testing.T) {
+		var out bytes.Buffer
+		configReader := createConfigFileReader(t, "owner/empty:\n")
+
+		err := runLint(configReader, testConfigPath, formatText, &out)
+
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), "warning: owner/empty: entry is empty")
+		assert.Equal(t, testConfigPath, configReader.path)
+	})
+
+	t.Run("should write the findings as JSON", func(t *testing.T) {
+		var out bytes.Buffer
+
+		err := runLint(createConfigFileReader(t, "owner/empty:\n"), testConfigPath, formatJSON, &out)
+
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), `"rule": "empty-repository"`)
+	})
+
+	t.Run("should return an error if a finding is an error", func(t *testing.T) {
+		var builder strings.Builder
+		builder.WriteString("owner/repo:\n")
+		for i := range 101 {
+			builder.WriteString("  KEY_" + strings.Repeat("X", i+1) + ": op://vault/item/key\n")
+		}
+
+		err := runLint(createConfigFileReader(t, builder.String()), testConfigPath, formatText, &bytes.Buffer{})
+
+		assert.ErrorContains(t, err, "configuration has lint errors")
+	})
+
+	t.Run("should return an error for unknown formats before reading the config", func(t *testing.T) {
+		configReader := createConfigFileReader(t, "owner/empty:\n")
+
+		err := runLint(configReader, testConfigPath, "xml", &bytes.Buffer{})
+
+		assert.ErrorContains(t, err, "unknown output format xml")
+		assert.Equal(t, 0, configReader.calls)
+	})
+
+	t.Run("should return the error if reading the config failed", func(t *testing.T) {
+		err := runLint(&MockConfigFileReader{expectedError: assert.AnError}, testConfigPath, formatText, &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
@@ -1,38 +1,322 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/state"
 	"koenighotze.de/github-distribute-secrets/pkg/github"
 	"koenighotze.de/github-distribute-secrets/pkg/onepassword"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
 )
 
+// Backends reading secrets from 1Password. The backend is chosen with
+// --op-backend, which defaults to the OP_BACKEND environment variable.
+const (
+	opBackendCLI     = "cli"
+	opBackendConnect = "connect"
+	opBackendEnv     = "OP_BACKEND"
+)
+
+// Backends writing to GitHub. The backend is chosen with --gh-backend, which
+// defaults to the GH_BACKEND environment variable.
+const (
+	ghBackendCLI = "cli"
+	ghBackendAPI = "api"
+	ghBackendEnv = "GH_BACKEND"
+)
+
+// Default limits for a single call of op or gh. Reading a secret may wait
+// for the user to authorize op, so op gets more time.
+const (
+	defaultOpTimeout = 2 * time.Minute
+	defaultGhTimeout = time.Minute
+)
+
+// defaultStatePath is the state file remembering fingerprints of the values
+// written by earlier runs.
+const defaultStatePath = "./.github-distribute-secrets.state"
+
 var (
 	myNewGhClient              = github.NewClient
+	myNewGhRestClient          = github.NewRestClient
+	myGhToken                  = github.Token
 	myNewOpClient              = onepassword.NewClient
+	myNewOpConnectClient       = onepassword.NewConnectClient
+	myNewSecretProvider        = newSecretProvider
 	myNewConfigFileReader      = config.NewConfigFileReader
 	myGithubSecretDistribution = githubSecretDistribution
+	myRunLint                  = runLint
+	myRunDiff                  = runDiff
+	myRunPlan                  = runPlan
+	myRunApply                 = runApply
+	myLoadState                = state.Load
 )
 
+func newOnePasswordClient(backend string, timeout time.Duration) (onepassword.OnePasswordClient, error) {
+	switch backend {
+	case opBackendCLI:
+		return myNewOpClient(timeout), nil
+	case opBackendConnect:
+		host, token := os.Getenv(onepassword.ConnectHostEnv), os.Getenv(onepassword.ConnectTokenEnv)
+		if host == "" || token == "" {
+			return nil, fmt.Errorf("%s and %s must be set to use 1Password Connect", onepassword.ConnectHostEnv, onepassword.ConnectTokenEnv)
+		}
+		return myNewOpConnectClient(host, token, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown 1Password backend %s, expected %s or %s", backend, opBackendCLI, opBackendConnect)
+	}
+}
+
+func defaultOnePasswordBackend() string {
+	if backend, ok := os.LookupEnv(opBackendEnv); ok && backend != "" {
+		return backend
+	}
+	return opBackendCLI
+}
+
+func newGithubClient(ctx context.Context, backend string, dryRun bool, timeout time.Duration) (github.GithubClient, error) {
+	switch backend {
+	case ghBackendCLI:
+		return myNewGhClient(dryRun, timeout), nil
+	case ghBackendAPI:
+		token, err := myGhToken(ctx, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get a token for the GitHub API: %w", err)
+		}
+		return myNewGhRestClient(token, dryRun, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown GitHub backend %s, expected %s or %s", backend, ghBackendCLI, ghBackendAPI)
+	}
+}
+
+func defaultGithubBackend() string {
+	if backend, ok := os.LookupEnv(ghBackendEnv); ok && backend != "" {
+		return backend
+	}
+	return ghBackendCLI
+}
+
+// skipUnchanged wraps the client so values that are unchanged since the last
+// run are not written again. The returned function logs how many values were
+// written and skipped and saves the state, except in dry run mode.
+func skipUnchanged(gh github.GithubClient, statePath string, force bool, dryRun bool) (github.GithubClient, func(), error) {
+	if statePath == "" {
+		return gh, func() {}, nil
+	}
+
+	store, err := myLoadState(statePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	skipping := state.NewSkippingClient(gh, store, force)
+	finish := func() {
+		log.Printf("Summary: %d secrets and variables written, %d skipped as unchanged", skipping.Written(), skipping.Skipped())
+		if dryRun {
+			return
+		}
+		if err := store.Save(); err != nil {
+			log.Println(err)
+		}
+	}
+
+	return skipping, finish, nil
+}
+
+func newSecretProvider(op onepassword.OnePasswordClient) provider.Provider {
+	return provider.NewRegistry(op)
+}
+
+func lintCommand(configPath string, args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	format := flags.String("format", formatText, "Output format of the findings, text or json")
+	_ = flags.Parse(args)
+
+	if err := myRunLint(myNewConfigFileReader(), configPath, *format, os.Stdout); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func diffCommand(configPath string, ghBackend string, ghTimeout time.Duration, args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := flags.String("format", formatText, "Output format of the differences, text or json")
+	_ = flags.Parse(args)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	gh, err := newGithubClient(ctx, ghBackend, false, ghTimeout)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := myRunDiff(ctx, myNewConfigFileReader(), configPath, gh, *format, os.Stdout); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// backends selects and limits the backends reading secrets and writing to
+// GitHub.
+type backends struct {
+	op        string
+	opTimeout time.Duration
+	gh        string
+	ghTimeout time.Duration
+}
+
+// planningClients returns the secrets, the GitHub client and the state plan
+// and apply work with. Both need the state, as the plan compares fingerprints.
+func planningClients(ctx context.Context, backends backends, statePath string) (provider.Provider, github.GithubClient, *state.State, error) {
+	if statePath == "" {
+		return nil, nil, nil, fmt.Errorf("planning needs a state file, --state must not be empty")
+	}
+
+	op, err := newOnePasswordClient(backends.op, backends.opTimeout)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	gh, err := newGithubClient(ctx, backends.gh, false, backends.ghTimeout)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	store, err := myLoadState(statePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return myNewSecretProvider(op), gh, store, nil
+}
+
+// planFileArgument parses the arguments of plan and apply, which take the
+// path of the plan file after their flags.
+func planFileArgument(flags *flag.FlagSet, args []string) string {
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		log.Fatalf("Usage: %s [flags] <planfile>", flags.Name())
+	}
+	return flags.Arg(0)
+}
+
+func planCommand(configPath string, backends backends, statePath string, prune bool, force bool, args []string) {
+	flags := flag.NewFlagSet("plan", flag.ExitOnError)
+	planPrune := flags.Bool("prune", prune, "Delete repository secrets that are not configured")
+	planForce := flags.Bool("force", force, "Write values even if they are unchanged since the last run")
+	planPath := planFileArgument(flags, args)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	secrets, gh, store, err := planningClients(ctx, backends, statePath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := myRunPlan(ctx, myNewConfigFileReader(), configPath, secrets, gh, store, *planPrune, *planForce, planPath, os.Stdout); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func applyCommand(configPath string, backends backends, statePath string, args []string) {
+	planPath := planFileArgument(flag.NewFlagSet("apply", flag.ExitOnError), args)
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	secrets, gh, store, err := planningClients(ctx, backends, statePath)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	if err := myRunApply(ctx, myNewConfigFileReader(), configPath, secrets, gh, store, planPath); err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// interruptContext returns a context cancelled by Ctrl-C. It cancels the
+// commands in flight, a second Ctrl-C exits right away.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
 func main() {
+	configPath := flag.String("config", "./config.yml", "Configuration file or directory of configuration fragments")
 	dryRun := flag.Bool("dry-run", false, "Simulate execution without making changes")
 	dumpConfig := flag.Bool("dump-config", false, "Dump configuration without applying it")
+	prune := flag.Bool("prune", false, "Delete repository secrets that are not configured")
+	statePath := flag.String("state", defaultStatePath, "File remembering fingerprints of the written values, empty to write every value")
+	force := flag.Bool("force", false, "Write values even if they are unchanged since the last run")
+	opBackend := flag.String("op-backend", defaultOnePasswordBackend(), "Read 1Password secrets with the op CLI (cli) or a 1Password Connect server (connect)")
+	opTimeout := flag.Duration("op-timeout", defaultOpTimeout, "Stop reading a secret from 1Password after this duration")
+	ghBackend := flag.String("gh-backend", defaultGithubBackend(), "Write to GitHub with the gh CLI (cli) or the REST API (api)")
+	ghTimeout := flag.Duration("gh-timeout", defaultGhTimeout, "Stop a single gh command after this duration")
 	flag.Parse()
 
+	switch flag.Arg(0) {
+	case "":
+	case "lint":
+		lintCommand(*configPath, flag.Args()[1:])
+		return
+	case "diff":
+		diffCommand(*configPath, *ghBackend, *ghTimeout, flag.Args()[1:])
+		return
+	case "plan":
+		planCommand(*configPath, backends{*opBackend, *opTimeout, *ghBackend, *ghTimeout}, *statePath, *prune, *force, flag.Args()[1:])
+		return
+	case "apply":
+		applyCommand(*configPath, backends{*opBackend, *opTimeout, *ghBackend, *ghTimeout}, *statePath, flag.Args()[1:])
+		return
+	default:
+		log.Fatalf("Unknown command %s", flag.Arg(0))
+	}
+
 	if *dryRun {
 		log.Println("RUNNING IN DRY-RUN MODE - Will not change anything!")
 	}
 
+	if *prune {
+		log.Println("PRUNING ENABLED - Secrets missing from the configuration will be deleted!")
+	}
+
 	if *dumpConfig {
 		log.Println("CONFIGURATION DUMP ENABLED - Configuration will be printed")
 	}
 
-	gh := myNewGhClient(*dryRun)
-	op := myNewOpClient()
+	op, err := newOnePasswordClient(*opBackend, *opTimeout)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Ctrl-C also skips the remaining repositories.
+	ctx, stop := interruptContext()
+	defer stop()
+
+	gh, err := newGithubClient(ctx, *ghBackend, *dryRun, *ghTimeout)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	gh, finish, err := skipUnchanged(gh, *statePath, *force, *dryRun)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	secrets := myNewSecretProvider(op)
 
-	if err := myGithubSecretDistribution(myNewConfigFileReader(), op, gh, *dumpConfig); err != nil {
+	err = myGithubSecretDistribution(ctx, myNewConfigFileReader(), *configPath, secrets, gh, *dumpConfig, *prune)
+	finish()
+	if err != nil {
 		log.Fatalln(err)
 	}
 }
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"io"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/state"
 	"koenighotze.de/github-distribute-secrets/pkg/github"
 	"koenighotze.de/github-distribute-secrets/pkg/onepassword"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
 )
 
 func TestMain(t *testing.T) {
@@ -18,19 +24,33 @@ func TestMain(t *testing.T) {
 	originalArgs := os.Args
 	orignalMyNewGhClient := myNewGhClient
 	originalMyGithubSecretDistribution := myGithubSecretDistribution
+	originalMyRunLint := myRunLint
+	originalMyRunDiff := myRunDiff
+	originalMyLoadState := myLoadState
+	originalMyRunPlan := myRunPlan
+	originalMyRunApply := myRunApply
 
 	defer func() {
+		myRunPlan = originalMyRunPlan
+		myRunApply = originalMyRunApply
+		myLoadState = originalMyLoadState
+		myRunDiff = originalMyRunDiff
 		myNewGhClient = orignalMyNewGhClient
 		myGithubSecretDistribution = originalMyGithubSecretDistribution
+		myRunLint = originalMyRunLint
 		os.Args = originalArgs
 	}()
 
 	os.Args = []string{"cmd"}
-	myNewGhClient = func(dryRun bool) github.GithubClient {
+	statePath := filepath.Join(t.TempDir(), "state")
+	myLoadState = func(path string) (*state.State, error) {
+		return state.Load(statePath)
+	}
+	myNewGhClient = func(dryRun bool, timeout time.Duration) github.GithubClient {
 		calledNewGhClientWithValue = dryRun
-		return &mockGithubClient{}
+		return &github.MockGithubClient{}
 	}
-	myGithubSecretDistribution = func(configFileReader config.ConfigFileReader, op onepassword.OnePasswordClient, gh github.GithubClient, dumpConfig bool) error {
+	myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
 		calledGithubSecretDistribution = true
 		return nil
 	}
@@ -49,7 +69,7 @@ func TestMain(t *testing.T) {
 		os.Args = []string{"cmd", "--dump-config"}
 
 		dumpFlagValue := false
-		myGithubSecretDistribution = func(configFileReader config.ConfigFileReader, op onepassword.OnePasswordClient, gh github.GithubClient, dumpConfig bool) error {
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
 			dumpFlagValue = dumpConfig
 			return nil
 		}
@@ -59,12 +79,45 @@ func TestMain(t *testing.T) {
 		assert.True(t, dumpFlagValue, "Should pass true for dump flag when --dump-config is provided")
 	})
 
+	t.Run("should pass prune=true to githubSecretDistribution when --prune flag is provided", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd", "--prune"}
+
+		pruneFlagValue := false
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			pruneFlagValue = prune
+			return nil
+		}
+
+		main()
+
+		assert.True(t, pruneFlagValue)
+	})
+
+	t.Run("should skip unchanged values with the state file", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd"}
+
+		var usedClient github.GithubClient
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			usedClient = gh
+			return gh.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+		}
+
+		main()
+
+		assert.IsType(t, &state.SkippingClient{}, usedClient)
+		saved, err := state.Load(statePath)
+		assert.NoError(t, err)
+		assert.True(t, saved.Unchanged("owner/repo/actions/KEY", "value"))
+	})
+
 	t.Run("should pass dump=false to githubSecretDistribution when --dump-config flag is not provided", func(t *testing.T) {
 		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 		os.Args = []string{"cmd"}
 
 		dumpFlagValue := true
-		myGithubSecretDistribution = func(configFileReader config.ConfigFileReader, op onepassword.OnePasswordClient, gh github.GithubClient, dumpConfig bool) error {
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
 			dumpFlagValue = dumpConfig
 			return nil
 		}
@@ -74,6 +127,160 @@ func TestMain(t *testing.T) {
 		assert.False(t, dumpFlagValue, "Should pass false for dump flag when --dump-config is not provided")
 	})
 
+	t.Run("should pass the config path to githubSecretDistribution", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd", "--config", "configs/"}
+
+		passedConfigPath := ""
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			passedConfigPath = configPath
+			return nil
+		}
+
+		main()
+
+		assert.Equal(t, "configs/", passedConfigPath)
+	})
+
+	t.Run("should read ./config.yml if no config path is provided", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd"}
+
+		passedConfigPath := ""
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			passedConfigPath = configPath
+			return nil
+		}
+
+		main()
+
+		assert.Equal(t, "./config.yml", passedConfigPath)
+	})
+
+	t.Run("should lint the configuration instead of distributing it", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd", "--config", "configs/", "lint", "--format", "json"}
+
+		distributed := false
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			distributed = true
+			return nil
+		}
+		lintedConfigPath, lintFormat := "", ""
+		myRunLint = func(configFileReader config.ConfigFileReader, configPath string, format string, out io.Writer) error {
+			lintedConfigPath, lintFormat = configPath, format
+			return nil
+		}
+
+		main()
+
+		assert.False(t, distributed)
+		assert.Equal(t, "configs/", lintedConfigPath)
+		assert.Equal(t, "json", lintFormat)
+	})
+
+	t.Run("should compare the repositories with the configuration instead of distributing it", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd", "--config", "configs/", "diff", "--format", "json"}
+
+		distributed := false
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			distributed = true
+			return nil
+		}
+		diffedConfigPath, diffFormat := "", ""
+		var diffClient github.GithubClient
+		myRunDiff = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, gh github.GithubClient, format string, out io.Writer) error {
+			diffedConfigPath, diffFormat, diffClient = configPath, format, gh
+			return nil
+		}
+
+		main()
+
+		assert.False(t, distributed)
+		assert.Equal(t, "configs/", diffedConfigPath)
+		assert.Equal(t, "json", diffFormat)
+		assert.IsType(t, &github.MockGithubClient{}, diffClient)
+	})
+
+	t.Run("should write a plan instead of distributing the configuration", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd", "--config", "configs/", "--prune", "plan", "plan.json"}
+
+		distributed := false
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			distributed = true
+			return nil
+		}
+		plannedConfigPath, planPath, planPrune := "", "", false
+		myRunPlan = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, store *state.State, prune bool, force bool, path string, out io.Writer) error {
+			plannedConfigPath, planPath, planPrune = configPath, path, prune
+			return nil
+		}
+
+		main()
+
+		assert.False(t, distributed)
+		assert.Equal(t, "configs/", plannedConfigPath)
+		assert.Equal(t, "plan.json", planPath)
+		assert.True(t, planPrune)
+	})
+
+	t.Run("should take the flags of the plan after the plan command", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd", "--config", "configs/", "plan", "--prune", "--force", "plan.json"}
+
+		planPath, planPrune, planForce := "", false, false
+		myRunPlan = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, store *state.State, prune bool, force bool, path string, out io.Writer) error {
+			planPath, planPrune, planForce = path, prune, force
+			return nil
+		}
+
+		main()
+
+		assert.Equal(t, "plan.json", planPath)
+		assert.True(t, planPrune)
+		assert.True(t, planForce)
+	})
+
+	t.Run("should apply a plan instead of distributing the configuration", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd", "--config", "configs/", "apply", "plan.json"}
+
+		distributed := false
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			distributed = true
+			return nil
+		}
+		appliedPlanPath := ""
+		var applyClient github.GithubClient
+		myRunApply = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, store *state.State, path string) error {
+			appliedPlanPath, applyClient = path, gh
+			return nil
+		}
+
+		main()
+
+		assert.False(t, distributed)
+		assert.Equal(t, "plan.json", appliedPlanPath)
+		assert.IsType(t, &github.MockGithubClient{}, applyClient)
+	})
+
+	t.Run("should resolve secrets through the provider registry", func(t *testing.T) {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd"}
+
+		var usedProvider provider.Provider
+		myGithubSecretDistribution = func(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, dumpConfig bool, prune bool) error {
+			usedProvider = secrets
+			return nil
+		}
+
+		main()
+
+		assert.IsType(t, &provider.Registry{}, usedProvider)
+	})
+
 	t.Run("should use the default client if the flag is omitted", func(t *testing.T) {
 		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 		os.Args = []string{"cmd"}
@@ -95,3 +302,170 @@ func TestMain(t *testing.T) {
 		t.Skip("skipping until we can test exit in a sane way")
 	})
 }
+
+func TestNewOnePasswordClient(t *testing.T) {
+	originalMyNewOpConnectClient := myNewOpConnectClient
+	defer func() {
+		myNewOpConnectClient = originalMyNewOpConnectClient
+	}()
+
+	t.Run("should use the op CLI by default", func(t *testing.T) {
+		t.Setenv(opBackendEnv, "")
+
+		assert.Equal(t, opBackendCLI, defaultOnePasswordBackend())
+	})
+
+	t.Run("should take the default backend from the environment", func(t *testing.T) {
+		t.Setenv(opBackendEnv, opBackendConnect)
+
+		assert.Equal(t, opBackendConnect, defaultOnePasswordBackend())
+	})
+
+	t.Run("should return the op CLI client", func(t *testing.T) {
+		result, err := newOnePasswordClient(opBackendCLI, time.Minute)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+	})
+
+	t.Run("should return the 1Password Connect client", func(t *testing.T) {
+		t.Setenv(onepassword.ConnectHostEnv, "http://localhost:8080")
+		t.Setenv(onepassword.ConnectTokenEnv, "token")
+		calledHost, calledToken := "", ""
+		myNewOpConnectClient = func(host string, token string, timeout time.Duration) onepassword.OnePasswordClient {
+			calledHost, calledToken = host, token
+			return &MockOnePasswordClient{}
+		}
+
+		result, err := newOnePasswordClient(opBackendConnect, time.Minute)
+
+		assert.NoError(t, err)
+		assert.IsType(t, &MockOnePasswordClient{}, result)
+		assert.Equal(t, "http://localhost:8080", calledHost)
+		assert.Equal(t, "token", calledToken)
+	})
+
+	t.Run("should return an error if 1Password Connect is not configured", func(t *testing.T) {
+		t.Setenv(onepassword.ConnectHostEnv, "")
+
+		_, err := newOnePasswordClient(opBackendConnect, time.Minute)
+
+		assert.EqualError(t, err, "OP_CONNECT_HOST and OP_CONNECT_TOKEN must be set to use 1Password Connect")
+	})
+
+	t.Run("should return an error for unknown backends", func(t *testing.T) {
+		_, err := newOnePasswordClient("sdk", time.Minute)
+
+		assert.EqualError(t, err, "unknown 1Password backend sdk, expected cli or connect")
+	})
+}
+
+func TestNewGithubClient(t *testing.T) {
+	originalMyNewGhRestClient := myNewGhRestClient
+	originalMyGhToken := myGhToken
+	defer func() {
+		myNewGhRestClient = originalMyNewGhRestClient
+		myGhToken = originalMyGhToken
+	}()
+
+	t.Run("should use the gh CLI by default", func(t *testing.T) {
+		t.Setenv(ghBackendEnv, "")
+
+		assert.Equal(t, ghBackendCLI, defaultGithubBackend())
+	})
+
+	t.Run("should take the default backend from the environment", func(t *testing.T) {
+		t.Setenv(ghBackendEnv, ghBackendAPI)
+
+		assert.Equal(t, ghBackendAPI, defaultGithubBackend())
+	})
+
+	t.Run("should return the gh CLI client", func(t *testing.T) {
+		result, err := newGithubClient(context.Background(), ghBackendCLI, false, time.Minute)
+
+		assert.NoError(t, err)
+		assert.NotNil(t, result)
+	})
+
+	t.Run("should return the REST API client with the resolved token", func(t *testing.T) {
+		myGhToken = func(ctx context.Context, timeout time.Duration) (string, error) {
+			return "token", nil
+		}
+		calledToken, calledDryRun := "", false
+		myNewGhRestClient = func(token string, dryRun bool, timeout time.Duration) github.GithubClient {
+			calledToken, calledDryRun = token, dryRun
+			return &github.MockGithubClient{}
+		}
+
+		result, err := newGithubClient(context.Background(), ghBackendAPI, true, time.Minute)
+
+		assert.NoError(t, err)
+		assert.IsType(t, &github.MockGithubClient{}, result)
+		assert.Equal(t, "token", calledToken)
+		assert.True(t, calledDryRun)
+	})
+
+	t.Run("should return an error if there is no token", func(t *testing.T) {
+		myGhToken = func(ctx context.Context, timeout time.Duration) (string, error) {
+			return "", assert.AnError
+		}
+
+		_, err := newGithubClient(context.Background(), ghBackendAPI, false, time.Minute)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed to get a token for the GitHub API")
+	})
+
+	t.Run("should return an error for unknown backends", func(t *testing.T) {
+		_, err := newGithubClient(context.Background(), "graphql", false, time.Minute)
+
+		assert.EqualError(t, err, "unknown GitHub backend graphql, expected cli or api")
+	})
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	t.Run("should write every value without a state file", func(t *testing.T) {
+		githubClient := &github.MockGithubClient{}
+
+		result, finish, err := skipUnchanged(githubClient, "", false, false)
+		finish()
+
+		assert.NoError(t, err)
+		assert.Same(t, githubClient, result)
+	})
+
+	t.Run("should not save the state in dry run mode", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "state")
+
+		result, finish, err := skipUnchanged(&github.MockGithubClient{}, statePath, false, true)
+		assert.NoError(t, err)
+		_ = result.AddSecretToRepository(context.Background(), "KEY", "value", "owner/repo")
+		finish()
+
+		_, err = os.Stat(statePath)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("should return an error if the state cannot be read", func(t *testing.T) {
+		statePath := filepath.Join(t.TempDir(), "state")
+		assert.NoError(t, os.WriteFile(statePath, []byte("not json"), 0o600))
+
+		_, _, err := skipUnchanged(&github.MockGithubClient{}, statePath, false, false)
+
+		assert.ErrorContains(t, err, "is malformed")
+	})
+}
+
+func TestPlanningClients(t *testing.T) {
+	t.Run("should need a state file", func(t *testing.T) {
+		_, _, _, err := planningClients(context.Background(), backends{op: opBackendCLI, gh: ghBackendCLI}, "")
+
+		assert.ErrorContains(t, err, "planning needs a state file")
+	})
+
+	t.Run("should return the error of an unknown backend", func(t *testing.T) {
+		_, _, _, err := planningClients(context.Background(), backends{op: "unknown", gh: ghBackendCLI}, filepath.Join(t.TempDir(), "state"))
+
+		assert.ErrorContains(t, err, "unknown 1Password backend unknown")
+	})
+}
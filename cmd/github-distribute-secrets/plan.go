@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/plan"
+	"koenighotze.de/github-distribute-secrets/internal/state"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
+)
+
+// makePlan plans applying the configuration at configPath.
+func makePlan(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, store *state.State, prune bool, force bool) (*plan.Plan, error) {
+	configuration, err := configFileReader.ReadConfiguration(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	fingerprint, err := plan.Fingerprint(configuration)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = configuration.ExpandPatterns(func(owner string) ([]string, error) {
+		return gh.ListRepositories(ctx, owner)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to expand repository patterns: %w", err)
+	}
+
+	prefetchSecrets(ctx, configuration, secrets)
+
+	return plan.Build(ctx, configuration, fingerprint, secrets, gh, store, prune, force)
+}
+
+// runPlan prints the plan and writes it to planPath. The state is saved as
+// well, so a new state keeps the salt the fingerprints of the plan use.
+func runPlan(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, store *state.State, prune bool, force bool, planPath string, out io.Writer) error {
+	planned, err := makePlan(ctx, configFileReader, configPath, secrets, gh, store, prune, force)
+	if err != nil {
+		return err
+	}
+
+	if err = planned.WriteText(out); err != nil {
+		return fmt.Errorf("failed to write the plan: %w", err)
+	}
+	if err = store.Save(); err != nil {
+		return err
+	}
+	return planned.Save(planPath)
+}
+
+// runApply applies the plan at planPath. The plan is made again and applied
+// only if it is still the same, otherwise nothing is changed.
+func runApply(ctx context.Context, configFileReader config.ConfigFileReader, configPath string, secrets provider.Provider, gh github.GithubClient, store *state.State, planPath string) error {
+	planned, err := plan.Read(planPath)
+	if err != nil {
+		return err
+	}
+
+	current, err := makePlan(ctx, configFileReader, configPath, secrets, gh, store, planned.Prune, planned.Force)
+	if err != nil {
+		return err
+	}
+	if err = current.Verify(planned); err != nil {
+		return fmt.Errorf("refusing to apply %s, plan again: %w", planPath, err)
+	}
+
+	ok := current.Apply(ctx, gh, store)
+	log.Printf("Summary: applied plan with %s", current)
+	if err = store.Save(); err != nil {
+		return err
+	}
+	if err = ctx.Err(); err != nil {
+		return fmt.Errorf("applying the plan was interrupted: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("plan was not applied successfully")
+	}
+
+	return nil
+}
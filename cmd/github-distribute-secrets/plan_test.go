@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"koenighotze.de/github-distribute-secrets/internal/plan"
+	"koenighotze.de/github-distribute-secrets/internal/state"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+func TestRunPlanAndApply(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	createState := func(t *testing.T) (*state.State, string) {
+		statePath := filepath.Join(t.TempDir(), "state")
+		store, err := state.Load(statePath)
+		assert.NoError(t, err)
+		return store, statePath
+	}
+	const content = "owner/a:\n  API_KEY: op://vault/api/key\n"
+
+	t.Run("should print and write the plan without changing anything", func(t *testing.T) {
+		var out bytes.Buffer
+		planPath := filepath.Join(t.TempDir(), "plan.json")
+		githubClient := &github.MockGithubClient{}
+		store, _ := createState(t)
+
+		err := runPlan(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, false, false, planPath, &out)
+
+		assert.NoError(t, err)
+		assert.Contains(t, out.String(), "create     owner/a/actions/API_KEY (not in GitHub)")
+		assert.Equal(t, 0, githubClient.RepositoryCalls)
+		planned, err := plan.Read(planPath)
+		assert.NoError(t, err)
+		assert.Len(t, planned.Actions, 1)
+	})
+
+	t.Run("should apply an unchanged plan", func(t *testing.T) {
+		planPath := filepath.Join(t.TempDir(), "plan.json")
+		githubClient := &github.MockGithubClient{}
+		store, statePath := createState(t)
+		assert.NoError(t, runPlan(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, false, false, planPath, &bytes.Buffer{}))
+
+		reloaded, _ := state.Load(statePath)
+		err := runApply(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, reloaded, planPath)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, githubClient.RepositoryCalls)
+		saved, _ := state.Load(statePath)
+		assert.True(t, saved.Unchanged("owner/a/actions/API_KEY", "something"))
+	})
+
+	t.Run("should apply the deletions of a pruning plan", func(t *testing.T) {
+		planPath := filepath.Join(t.TempDir(), "plan.json")
+		githubClient := &github.MockGithubClient{Secrets: map[string][]github.Secret{"owner/a": {{Name: "REMOVED", UpdatedAt: updatedAt}}}}
+		store, _ := createState(t)
+		assert.NoError(t, runPlan(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, true, false, planPath, &bytes.Buffer{}))
+
+		err := runApply(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, planPath)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/a/REMOVED"}, githubClient.Deleted)
+	})
+
+	t.Run("should refuse to apply if the configuration changed", func(t *testing.T) {
+		planPath := filepath.Join(t.TempDir(), "plan.json")
+		githubClient := &github.MockGithubClient{}
+		store, _ := createState(t)
+		assert.NoError(t, runPlan(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, false, false, planPath, &bytes.Buffer{}))
+
+		err := runApply(ctx, createConfigFileReader(t, content+"  NEW_KEY: op://vault/new/key\n"), testConfigPath, &MockOnePasswordClient{}, githubClient, store, planPath)
+
+		assert.ErrorIs(t, err, plan.ErrConfigurationChanged)
+		assert.Equal(t, 0, githubClient.RepositoryCalls)
+	})
+
+	t.Run("should refuse to apply if GitHub changed", func(t *testing.T) {
+		planPath := filepath.Join(t.TempDir(), "plan.json")
+		githubClient := &github.MockGithubClient{}
+		store, _ := createState(t)
+		assert.NoError(t, runPlan(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, false, false, planPath, &bytes.Buffer{}))
+		githubClient.Secrets = map[string][]github.Secret{"owner/a": {{Name: "API_KEY", UpdatedAt: updatedAt}}}
+
+		err := runApply(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, planPath)
+
+		assert.ErrorIs(t, err, plan.ErrRemoteChanged)
+		assert.ErrorContains(t, err, "plan again")
+		assert.Equal(t, 0, githubClient.RepositoryCalls)
+	})
+
+	t.Run("should return an error if the plan was not applied successfully", func(t *testing.T) {
+		planPath := filepath.Join(t.TempDir(), "plan.json")
+		githubClient := &github.MockGithubClient{}
+		store, _ := createState(t)
+		assert.NoError(t, runPlan(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, false, false, planPath, &bytes.Buffer{}))
+		githubClient.Error = assert.AnError
+
+		err := runApply(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, githubClient, store, planPath)
+
+		assert.ErrorContains(t, err, "plan was not applied successfully")
+	})
+
+	t.Run("should return an error if a secret cannot be read while planning", func(t *testing.T) {
+		store, _ := createState(t)
+
+		err := runPlan(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{expectedError: assert.AnError}, &github.MockGithubClient{}, store, false, false, filepath.Join(t.TempDir(), "plan.json"), &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("should return an error if the plan file cannot be read", func(t *testing.T) {
+		store, _ := createState(t)
+
+		err := runApply(ctx, createConfigFileReader(t, content), testConfigPath, &MockOnePasswordClient{}, &github.MockGithubClient{}, store, filepath.Join(t.TempDir(), "missing.json"))
+
+		assert.ErrorContains(t, err, "failed to read plan file")
+	})
+
+	t.Run("should return the error if reading the config failed", func(t *testing.T) {
+		store, _ := createState(t)
+
+		err := runPlan(ctx, &MockConfigFileReader{expectedError: assert.AnError}, testConfigPath, &MockOnePasswordClient{}, &github.MockGithubClient{}, store, false, false, filepath.Join(t.TempDir(), "plan.json"), &bytes.Buffer{})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
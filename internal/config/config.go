@@ -6,33 +6,410 @@ import (
 	"io"
 	"maps"
 	"os"
+	"path"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/goccy/go-yaml"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
+)
+
+const (
+	commonSection        = "common"
+	defaultsSection      = "defaults"
+	environmentsSection  = "environments"
+	excludeField         = "exclude"
+	inheritField         = "inherit"
+	groupsSection        = "groups"
+	includeSection       = "include"
+	organizationsSection = "organizations"
+	pruneField           = "prune"
+	variablesSection     = "variables"
+
+	onePasswordReferencePrefix = "op://"
+
+	// groupSectionPrefix marks the sections of groups. A colon is not
+	// allowed in repository names, so groups never clash with repositories.
+	groupSectionPrefix = "group:"
+)
+
+// Apps that can read repository secrets. Secrets are added to Actions unless
+// configured otherwise.
+const (
+	AppActions    = "actions"
+	AppDependabot = "dependabot"
+	AppCodespaces = "codespaces"
+)
+
+// Visibilities of organization secrets as understood by GitHub.
+const (
+	VisibilityAll      = "all"
+	VisibilityPrivate  = "private"
+	VisibilitySelected = "selected"
 )
 
 type RepositoryConfiguration map[string]string
+
+// EnvironmentConfiguration maps the name of a GitHub environment to the
+// secrets that should be added to that environment.
+type EnvironmentConfiguration map[string]RepositoryConfiguration
+
+// SecretApps maps the key of a secret to the apps it is added to.
+type SecretApps map[string][]string
+
+// OrganizationConfiguration describes the secrets of a GitHub organization.
+// Secrets with the selected visibility are shared with the configured
+// repositories of the organization.
+type OrganizationConfiguration struct {
+	Visibility string
+	Secrets    RepositoryConfiguration
+}
+
+// Defaults shorten the configuration. Repository keys without an owner are
+// qualified with Owner, and references may name a vault by one of the
+// aliases in Vaults, e.g. op://dev/item/field. Secrets matching a pattern
+// of NeverPrune are never deleted by pruning.
+type Defaults struct {
+	Owner      string
+	Vaults     map[string]string
+	NeverPrune []string
+}
+
 type Configuration struct {
-	RawConfig    map[string]RepositoryConfiguration
-	Repositories []string
+	Defaults      Defaults
+	RawConfig     map[string]RepositoryConfiguration
+	Environments  map[string]EnvironmentConfiguration
+	Organizations map[string]OrganizationConfiguration
+	Variables     map[string]RepositoryConfiguration
+	Apps          map[string]SecretApps
+	// IncludedGroups maps a repository to the groups it includes, in order.
+	IncludedGroups map[string][]string
+	// ExcludedCommon maps a repository to the patterns of common secrets it
+	// does not receive.
+	ExcludedCommon map[string][]string
+	// SkipCommon contains the repositories that do not inherit the common
+	// section at all.
+	SkipCommon map[string]bool
+	// SkipPrune contains the repositories whose unmanaged secrets are never
+	// pruned.
+	SkipPrune map[string]bool
+	// Patterns are the repository keys that are glob patterns. They are
+	// expanded against the repositories of their owner by ExpandPatterns.
+	Patterns []string
+	// MatchedPatterns maps an expanded repository to the pattern it matched.
+	MatchedPatterns map[string]string
+	Repositories    []string
 }
 type ConfigFileReader interface {
 	ReadConfiguration(path string) (config *Configuration, err error)
 }
 
+func groupSection(group string) string {
+	return groupSectionPrefix + group
+}
+
+func isGroupSection(section string) bool {
+	return strings.HasPrefix(section, groupSectionPrefix)
+}
+
+// sectionsForRepository returns the sections that make up the configuration
+// of a repository in order of precedence: common, the included groups in
+// order and the repository itself. Later sections override earlier ones.
+func (c Configuration) sectionsForRepository(repository string) []string {
+	section := c.repositorySection(repository)
+
+	sections := []string{}
+	if !c.SkipCommon[section] {
+		sections = append(sections, commonSection)
+	}
+	for _, group := range c.IncludedGroups[section] {
+		sections = append(sections, groupSection(group))
+	}
+	return append(sections, section)
+}
+
+// repositorySection returns the section configuring the repository, which
+// is the pattern it matched for expanded repositories.
+func (c Configuration) repositorySection(repository string) string {
+	if pattern, ok := c.MatchedPatterns[repository]; ok {
+		return pattern
+	}
+	return repository
+}
+
+func isRepositoryPattern(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+// ExpandPatterns adds every repository matching a pattern to the
+// repositories. The repositories of an owner are listed using the given
+// function. Explicitly configured repositories take precedence over patterns,
+// a repository matching more than one pattern is an error.
+func (c *Configuration) ExpandPatterns(listRepositories func(owner string) ([]string, error)) error {
+	repositoriesOfOwner := make(map[string][]string)
+
+	for _, pattern := range c.Patterns {
+		owner, _, _ := strings.Cut(pattern, "/")
+		if _, ok := repositoriesOfOwner[owner]; !ok {
+			repositories, err := listRepositories(owner)
+			if err != nil {
+				return fmt.Errorf("failed to list repositories of %s: %w", owner, err)
+			}
+			repositoriesOfOwner[owner] = repositories
+		}
+
+		for _, repository := range repositoriesOfOwner[owner] {
+			if matched, _ := path.Match(pattern, repository); !matched {
+				continue
+			}
+			if _, explicit := c.RawConfig[repository]; explicit {
+				continue
+			}
+			if other, ok := c.MatchedPatterns[repository]; ok {
+				return fmt.Errorf("repository %s matches the patterns %s and %s", repository, other, pattern)
+			}
+
+			if c.MatchedPatterns == nil {
+				c.MatchedPatterns = make(map[string]string)
+			}
+			c.MatchedPatterns[repository] = pattern
+			c.Repositories = append(c.Repositories, repository)
+		}
+	}
+
+	sort.Strings(c.Repositories)
+	return nil
+}
+
+// GetRepositoriesMatchingPattern returns the expanded repositories that
+// matched the pattern.
+func (c Configuration) GetRepositoriesMatchingPattern(pattern string) []string {
+	result := make([]string, 0)
+	for repository, matched := range c.MatchedPatterns {
+		if matched == pattern {
+			result = append(result, repository)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// GetGroups returns the names of all configured groups.
+func (c Configuration) GetGroups() []string {
+	result := make([]string, 0)
+	for section := range c.RawConfig {
+		if isGroupSection(section) {
+			result = append(result, strings.TrimPrefix(section, groupSectionPrefix))
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// isExcluded reports whether the repository opted out of the secret, the
+// environment secret or the variable named key of the section. Exclude
+// patterns apply to every value of the common section.
+func (c Configuration) isExcluded(repository string, section string, key string) bool {
+	if section != commonSection {
+		return false
+	}
+
+	for _, pattern := range c.ExcludedCommon[c.repositorySection(repository)] {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// GetCommonConfiguration returns the secrets of the common section.
+func (c Configuration) GetCommonConfiguration() RepositoryConfiguration {
+	return c.RawConfig[commonSection]
+}
+
+// GetConfigurationForRepository returns the secrets of the repository with
+// the placeholders of their references expanded.
 func (c Configuration) GetConfigurationForRepository(repository string) RepositoryConfiguration {
+	return expandSecrets(c.getTemplatesForRepository(repository), repository)
+}
+
+// getTemplatesForRepository returns the merged secrets of the repository
+// before placeholders are expanded.
+func (c Configuration) getTemplatesForRepository(repository string) RepositoryConfiguration {
 	merged := make(RepositoryConfiguration)
 
-	maps.Copy(merged, c.RawConfig["common"])
-	maps.Copy(merged, c.RawConfig[repository])
+	for _, section := range c.sectionsForRepository(repository) {
+		for key, reference := range c.RawConfig[section] {
+			if !c.isExcluded(repository, section, key) {
+				merged[key] = reference
+			}
+		}
+	}
 
 	return merged
 }
 
+// GetSuppressedCommonKeys returns the keys of the common secrets the
+// repository does not receive, either because they are excluded or because
+// the repository does not inherit the common section. Environment secrets
+// are prefixed with environments/ and their environment, variables with
+// variables/.
+func (c Configuration) GetSuppressedCommonKeys(repository string) []string {
+	result := make([]string, 0)
+	skipCommon := c.SkipCommon[c.repositorySection(repository)]
+	suppress := func(prefix string, keys map[string]string) {
+		for key := range keys {
+			if skipCommon || c.isExcluded(repository, commonSection, key) {
+				result = append(result, prefix+key)
+			}
+		}
+	}
+
+	suppress("", c.RawConfig[commonSection])
+	for environment, secrets := range c.Environments[commonSection] {
+		suppress("environments/"+environment+"/", secrets)
+	}
+	suppress("variables/", c.Variables[commonSection])
+
+	sort.Strings(result)
+	return result
+}
+
+// PruneEnabled reports whether unmanaged secrets of the repository may be
+// pruned, which a repository switches off with prune: false.
+func (c Configuration) PruneEnabled(repository string) bool {
+	return !c.SkipPrune[c.repositorySection(repository)]
+}
+
+// IsNeverPruned reports whether the secret matches a pattern of
+// defaults.never_prune.
+func (c Configuration) IsNeverPruned(name string) bool {
+	return slices.ContainsFunc(c.Defaults.NeverPrune, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+}
+
+// GetAppsForRepository returns the apps of every secret of the repository.
+// Like the secret itself, the apps of a secret replace the apps of a secret
+// with the same key in a section of lower precedence.
+func (c Configuration) GetAppsForRepository(repository string) SecretApps {
+	merged := make(SecretApps)
+
+	for _, section := range c.sectionsForRepository(repository) {
+		for key := range c.RawConfig[section] {
+			if c.isExcluded(repository, section, key) {
+				continue
+			}
+			apps, ok := c.Apps[section][key]
+			if !ok {
+				apps = []string{AppActions}
+			}
+			merged[key] = apps
+		}
+	}
+
+	return merged
+}
+
+// GetEnvironmentsForRepository returns the environment secrets of the
+// repository. Environment blocks of the common section and the included groups
+// are merged in, the repository blocks take precedence.
+func (c Configuration) GetEnvironmentsForRepository(repository string) EnvironmentConfiguration {
+	expanded := make(EnvironmentConfiguration)
+	for environment, secrets := range c.getEnvironmentTemplatesForRepository(repository) {
+		expanded[environment] = expandSecrets(secrets, repository)
+	}
+	return expanded
+}
+
+func (c Configuration) getEnvironmentTemplatesForRepository(repository string) EnvironmentConfiguration {
+	merged := make(EnvironmentConfiguration)
+
+	for _, section := range c.sectionsForRepository(repository) {
+		for environment, secrets := range c.Environments[section] {
+			for key, reference := range secrets {
+				if c.isExcluded(repository, section, key) {
+					continue
+				}
+				if merged[environment] == nil {
+					merged[environment] = make(RepositoryConfiguration)
+				}
+				merged[environment][key] = reference
+			}
+		}
+	}
+
+	return merged
+}
+
+// GetVariablesForRepository returns the Actions variables of the repository
+// merged with the variables of the common section and the included groups.
+func (c Configuration) GetVariablesForRepository(repository string) RepositoryConfiguration {
+	return expandSecrets(c.getVariableTemplatesForRepository(repository), repository)
+}
+
+func (c Configuration) getVariableTemplatesForRepository(repository string) RepositoryConfiguration {
+	merged := make(RepositoryConfiguration)
+
+	for _, section := range c.sectionsForRepository(repository) {
+		for name, value := range c.Variables[section] {
+			if !c.isExcluded(repository, section, name) {
+				merged[name] = value
+			}
+		}
+	}
+
+	return merged
+}
+
+// GetAllReferences returns every reference the configuration reads, once
+// each and in order: organization secrets first, then the secrets, environment
+// secrets and variables of the repositories.
+func (c Configuration) GetAllReferences() []string {
+	references := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(secrets RepositoryConfiguration) {
+		for _, key := range slices.Sorted(maps.Keys(secrets)) {
+			if reference := secrets[key]; !seen[reference] && provider.IsReference(reference) {
+				seen[reference] = true
+				references = append(references, reference)
+			}
+		}
+	}
+
+	for _, organization := range slices.Sorted(maps.Keys(c.Organizations)) {
+		add(c.Organizations[organization].Secrets)
+	}
+	for _, repository := range c.Repositories {
+		add(c.GetConfigurationForRepository(repository))
+		environments := c.GetEnvironmentsForRepository(repository)
+		for _, environment := range slices.Sorted(maps.Keys(environments)) {
+			add(environments[environment])
+		}
+		add(c.GetVariablesForRepository(repository))
+	}
+
+	return references
+}
+
+// GetSelectedRepositoriesForOrganization returns the configured repositories
+// that belong to the organization.
+func (c Configuration) GetSelectedRepositoriesForOrganization(organization string) []string {
+	result := make([]string, 0)
+	for _, repository := range c.Repositories {
+		if strings.HasPrefix(repository, organization+"/") {
+			result = append(result, repository)
+		}
+	}
+	return result
+}
+
 func extractRepositoryNamesFromConfig(rawConfig map[string]RepositoryConfiguration) []string {
 	result := make([]string, 0, len(rawConfig))
 	for key := range maps.Keys(rawConfig) {
-		if key == "common" {
+		if key == commonSection || isGroupSection(key) || isRepositoryPattern(key) {
 			continue
 		}
 
@@ -42,10 +419,69 @@ func extractRepositoryNamesFromConfig(rawConfig map[string]RepositoryConfigurati
 	return result
 }
 
+func extractPatternsFromConfig(rawConfig map[string]RepositoryConfiguration) ([]string, error) {
+	var result []string
+	for key := range maps.Keys(rawConfig) {
+		if isGroupSection(key) || !isRepositoryPattern(key) {
+			continue
+		}
+
+		owner, name, found := strings.Cut(key, "/")
+		if !found || isRepositoryPattern(owner) {
+			return nil, fmt.Errorf("pattern %s must have the form owner/pattern", key)
+		}
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("pattern %s is malformed: %w", key, err)
+		}
+
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
 func NewConfigFromReader(reader io.Reader) (config *Configuration, err error) {
-	config = &Configuration{}
+	var document map[string]any
 	dec := yaml.NewDecoder(reader)
-	if err = dec.Decode(&config.RawConfig); err != nil {
+	if err = dec.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	return newConfigFromDocument(document)
+}
+
+func newConfigFromDocument(document map[string]any) (config *Configuration, err error) {
+	config = &Configuration{
+		RawConfig: make(map[string]RepositoryConfiguration, len(document)),
+	}
+	if config.Defaults, err = decodeDefaults(document[defaultsSection]); err != nil {
+		return nil, err
+	}
+
+	for name, rawEntry := range document {
+		switch name {
+		case defaultsSection:
+		case organizationsSection:
+			config.Organizations, err = decodeOrganizations(rawEntry)
+		case groupsSection:
+			err = config.addGroups(rawEntry)
+		case includeSection:
+			err = fmt.Errorf("%s is only supported when reading configuration files", includeSection)
+		default:
+			err = config.addRepository(config.Defaults.qualify(name), rawEntry)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = config.checkIncludedGroups(); err != nil {
+		return nil, err
+	}
+
+	config.resolveVaultAliases()
+
+	if config.Patterns, err = extractPatternsFromConfig(config.RawConfig); err != nil {
 		return nil, err
 	}
 
@@ -54,22 +490,170 @@ func NewConfigFromReader(reader io.Reader) (config *Configuration, err error) {
 	return config, nil
 }
 
+func (c *Configuration) addRepository(repository string, rawEntry any) error {
+	if _, ok := c.RawConfig[repository]; ok {
+		return fmt.Errorf("repository %s is configured more than once", repository)
+	}
+	return c.addRepositoryEntry(repository, repository, rawEntry)
+}
+
+func (c *Configuration) addRepositoryEntry(section string, name string, rawEntry any) error {
+	entry, err := decodeRepositoryEntry(name, rawEntry)
+	if err != nil {
+		return err
+	}
+
+	c.RawConfig[section] = entry.secrets
+	if entry.environments != nil {
+		if c.Environments == nil {
+			c.Environments = make(map[string]EnvironmentConfiguration)
+		}
+		c.Environments[section] = entry.environments
+	}
+	if entry.apps != nil {
+		if c.Apps == nil {
+			c.Apps = make(map[string]SecretApps)
+		}
+		c.Apps[section] = entry.apps
+	}
+	if entry.variables != nil {
+		if c.Variables == nil {
+			c.Variables = make(map[string]RepositoryConfiguration)
+		}
+		c.Variables[section] = entry.variables
+	}
+	if (entry.exclude != nil || entry.inherit != nil) && (section == commonSection || isGroupSection(section)) {
+		return fmt.Errorf("%s cannot opt out of common secrets, only repositories can", name)
+	}
+	if entry.exclude != nil {
+		if c.ExcludedCommon == nil {
+			c.ExcludedCommon = make(map[string][]string)
+		}
+		c.ExcludedCommon[section] = entry.exclude
+	}
+	if entry.inherit != nil && !*entry.inherit {
+		if c.SkipCommon == nil {
+			c.SkipCommon = make(map[string]bool)
+		}
+		c.SkipCommon[section] = true
+	}
+	if entry.prune != nil {
+		if section == commonSection || isGroupSection(section) {
+			return fmt.Errorf("%s cannot switch off pruning, only repositories can", name)
+		}
+		if !*entry.prune {
+			if c.SkipPrune == nil {
+				c.SkipPrune = make(map[string]bool)
+			}
+			c.SkipPrune[section] = true
+		}
+	}
+	if entry.groups != nil {
+		if section == commonSection || isGroupSection(section) {
+			return fmt.Errorf("%s cannot include groups, only repositories can", name)
+		}
+		if c.IncludedGroups == nil {
+			c.IncludedGroups = make(map[string][]string)
+		}
+		c.IncludedGroups[section] = entry.groups
+	}
+
+	return nil
+}
+
+func (c *Configuration) addGroups(rawGroups any) error {
+	if rawGroups == nil {
+		return nil
+	}
+
+	groups, ok := rawGroups.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s must be a mapping", groupsSection)
+	}
+
+	for group, rawEntry := range groups {
+		if err := c.addRepositoryEntry(groupSection(group), "group "+group, rawEntry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Configuration) checkIncludedGroups() error {
+	for _, repository := range slices.Sorted(maps.Keys(c.IncludedGroups)) {
+		for _, group := range c.IncludedGroups[repository] {
+			if _, ok := c.RawConfig[groupSection(group)]; !ok {
+				return fmt.Errorf("repository %s includes unknown group %s", repository, group)
+			}
+		}
+	}
+	return nil
+}
+
 type configFileReader struct {
 	fileReader func(name string) ([]byte, error)
+	dirReader  func(name string) ([]os.DirEntry, error)
 }
 
+// ReadConfiguration reads the configuration from a file or from all
+// fragments of a directory. Files included by the fragments are read as well.
+// Every fragment is validated, all violations are reported at once.
 func (reader configFileReader) ReadConfiguration(path string) (config *Configuration, err error) {
-	configFile, err := reader.fileReader(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	loader := newFragmentLoader(reader)
+	if err = loader.load(path); err != nil {
+		return nil, err
+	}
+	loader.checkOwners()
+	if len(loader.violations) > 0 {
+		return nil, &ValidationError{Violations: loader.violations}
+	}
+	if err = loader.checkQualifiedRepositories(); err != nil {
+		return nil, err
 	}
 
-	return NewConfigFromReader(bytes.NewReader(configFile))
+	return newConfigFromDocument(loader.document)
 }
 
 func NewConfigFileReader() ConfigFileReader {
 	return configFileReader{
 		fileReader: os.ReadFile,
+		dirReader:  os.ReadDir,
+	}
+}
+
+// dumpValue formats a value together with the template it was expanded from,
+// if any.
+func dumpValue(value string, template string) string {
+	if template == "" || template == value {
+		return value
+	}
+	return fmt.Sprintf("%s (from %s)", value, template)
+}
+
+func dumpSecrets(buffer *bytes.Buffer, secrets RepositoryConfiguration, templates RepositoryConfiguration, apps SecretApps) {
+	for key, oppath := range secrets {
+		fmt.Fprintf(buffer, "  - %s: %s [%s]\n", key, dumpValue(oppath, templates[key]), strings.Join(apps[key], ", "))
+	}
+}
+
+func dumpEnvironments(buffer *bytes.Buffer, environments EnvironmentConfiguration, templates EnvironmentConfiguration) {
+	for _, environment := range slices.Sorted(maps.Keys(environments)) {
+		fmt.Fprintf(buffer, "  Environment %s:\n", environment)
+		for key, oppath := range environments[environment] {
+			fmt.Fprintf(buffer, "    - %s: %s\n", key, dumpValue(oppath, templates[environment][key]))
+		}
+	}
+}
+
+func dumpVariables(buffer *bytes.Buffer, variables RepositoryConfiguration, templates RepositoryConfiguration) {
+	if len(variables) == 0 {
+		return
+	}
+
+	buffer.WriteString("  Variables:\n")
+	for name, value := range variables {
+		fmt.Fprintf(buffer, "    - %s: %s\n", name, dumpValue(value, templates[name]))
 	}
 }
 
@@ -79,11 +663,68 @@ func (c Configuration) DumpConfiguration() string {
 	buffer.WriteString("Configuration Summary:\n")
 	buffer.WriteString("=====================\n\n")
 
-	commonConfig := c.RawConfig["common"]
-	if len(commonConfig) > 0 {
+	if c.Defaults.Owner != "" || len(c.Defaults.Vaults) > 0 || len(c.Defaults.NeverPrune) > 0 {
+		buffer.WriteString("Defaults:\n")
+		if c.Defaults.Owner != "" {
+			fmt.Fprintf(&buffer, "- Owner: %s (name expands to %s/name)\n", c.Defaults.Owner, c.Defaults.Owner)
+		}
+		for _, alias := range slices.Sorted(maps.Keys(c.Defaults.Vaults)) {
+			fmt.Fprintf(&buffer, "- Vault %s: %s%s/... expands to %s%s/...\n", alias,
+				onePasswordReferencePrefix, alias, onePasswordReferencePrefix, c.Defaults.Vaults[alias])
+		}
+		if len(c.Defaults.NeverPrune) > 0 {
+			fmt.Fprintf(&buffer, "- Never pruned: %s\n", strings.Join(c.Defaults.NeverPrune, ", "))
+		}
+		buffer.WriteString("\n")
+	}
+
+	commonConfig := c.RawConfig[commonSection]
+	commonEnvironments := c.Environments[commonSection]
+	commonVariables := c.Variables[commonSection]
+	if len(commonConfig) > 0 || len(commonEnvironments) > 0 || len(commonVariables) > 0 {
 		buffer.WriteString("Common Secrets (applied to all repositories):\n")
-		for key, oppath := range commonConfig {
-			fmt.Fprintf(&buffer, "  - %s: %s\n", key, oppath)
+		dumpSecrets(&buffer, commonConfig, nil, c.GetAppsForRepository(commonSection))
+		dumpEnvironments(&buffer, commonEnvironments, nil)
+		dumpVariables(&buffer, commonVariables, nil)
+		buffer.WriteString("\n")
+	}
+
+	if groups := c.GetGroups(); len(groups) > 0 {
+		buffer.WriteString("Groups (applied to including repositories):\n")
+		for _, group := range groups {
+			section := groupSection(group)
+			fmt.Fprintf(&buffer, "- %s:\n", group)
+			dumpSecrets(&buffer, c.RawConfig[section], nil, c.GetAppsForRepository(section))
+			dumpEnvironments(&buffer, c.Environments[section], nil)
+			dumpVariables(&buffer, c.Variables[section], nil)
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(c.Organizations) > 0 {
+		buffer.WriteString("Organization Secrets:\n")
+		for _, organization := range slices.Sorted(maps.Keys(c.Organizations)) {
+			orgConfig := c.Organizations[organization]
+			fmt.Fprintf(&buffer, "- %s (visibility %s):\n", organization, orgConfig.Visibility)
+			if orgConfig.Visibility == VisibilitySelected {
+				fmt.Fprintf(&buffer, "  Repositories: %s\n", strings.Join(c.GetSelectedRepositoriesForOrganization(organization), ", "))
+			}
+			for key, oppath := range orgConfig.Secrets {
+				fmt.Fprintf(&buffer, "  - %s: %s\n", key, oppath)
+			}
+		}
+		buffer.WriteString("\n")
+	}
+
+	if len(c.Patterns) > 0 {
+		buffer.WriteString("Repository Patterns:\n")
+		for _, pattern := range c.Patterns {
+			matches := c.GetRepositoriesMatchingPattern(pattern)
+			if len(matches) == 0 {
+				fmt.Fprintf(&buffer, "- %s: no matching repositories\n", pattern)
+			} else {
+				fmt.Fprintf(&buffer, "- %s: %s\n", pattern, strings.Join(matches, ", "))
+			}
 		}
 		buffer.WriteString("\n")
 	}
@@ -91,14 +732,28 @@ func (c Configuration) DumpConfiguration() string {
 	buffer.WriteString("Repository-Specific Configurations:\n")
 	for _, repo := range c.Repositories {
 		repoConfig := c.GetConfigurationForRepository(repo)
+		repoEnvironments := c.GetEnvironmentsForRepository(repo)
+		repoVariables := c.GetVariablesForRepository(repo)
 		fmt.Fprintf(&buffer, "- %s:\n", repo)
+		if pattern, ok := c.MatchedPatterns[repo]; ok {
+			fmt.Fprintf(&buffer, "  Matched by pattern: %s\n", pattern)
+		}
+		if groups := c.IncludedGroups[c.repositorySection(repo)]; len(groups) > 0 {
+			fmt.Fprintf(&buffer, "  Groups: %s\n", strings.Join(groups, ", "))
+		}
+		if suppressed := c.GetSuppressedCommonKeys(repo); len(suppressed) > 0 {
+			fmt.Fprintf(&buffer, "  Suppressed common secrets: %s\n", strings.Join(suppressed, ", "))
+		}
+		if !c.PruneEnabled(repo) {
+			buffer.WriteString("  Pruning disabled\n")
+		}
 
-		if len(repoConfig) == 0 {
+		if len(repoConfig) == 0 && len(repoEnvironments) == 0 && len(repoVariables) == 0 {
 			buffer.WriteString("  No secrets configured\n")
 		} else {
-			for key, oppath := range repoConfig {
-				fmt.Fprintf(&buffer, "  - %s: %s\n", key, oppath)
-			}
+			dumpSecrets(&buffer, repoConfig, c.getTemplatesForRepository(repo), c.GetAppsForRepository(repo))
+			dumpEnvironments(&buffer, repoEnvironments, c.getEnvironmentTemplatesForRepository(repo))
+			dumpVariables(&buffer, repoVariables, c.getVariableTemplatesForRepository(repo))
 		}
 		buffer.WriteString("\n")
 	}
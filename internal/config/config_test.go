@@ -5,6 +5,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,6 +19,14 @@ repo1:
    KEY1: VAL1
 repo2:
    KEY2: VAL2
+`
+	yamlConfigurationValid = `
+common:
+   KEY0: op://vault/common/key0
+owner/repo1:
+   KEY1: op://vault/repo1/key1
+owner/repo2:
+   KEY2: op://vault/repo2/key2
 `
 	yamlConfigurationCommonOnly = `
 common:
@@ -29,6 +38,139 @@ repo1:
    KEY1: VAL1
 `
 
+	yamlConfigurationEnvironments = `
+common:
+   KEY0: VAL0
+   environments:
+      production:
+         DEPLOY_KEY: common/production
+repo1:
+   KEY1: VAL1
+   environments:
+      staging:
+         DB_PASSWORD: repo1/staging
+      production:
+         DB_PASSWORD: repo1/production
+         DEPLOY_KEY: repo1/production
+repo2:
+   KEY2: VAL2
+`
+
+	yamlConfigurationOrganizations = `
+organizations:
+   org:
+      visibility: selected
+      secrets:
+         SONAR_TOKEN: op://sonar
+   other:
+      secrets:
+         DOCKER_REGISTRY_TOKEN: op://docker
+org/repo1:
+   KEY1: VAL1
+other/repo2:
+   KEY2: VAL2
+`
+
+	yamlConfigurationVariables = `
+common:
+   KEY0: VAL0
+   variables:
+      REGION: europe-west3
+repo1:
+   KEY1: VAL1
+   variables:
+      REGION: us-east1
+      GCP_RESOURCE_POSTFIX: op://vault/item/postfix
+repo2:
+   KEY2: VAL2
+`
+
+	yamlConfigurationApps = `
+common:
+   NPM_TOKEN:
+      ref: op://npm
+      apps: [actions, dependabot]
+   KEY0: VAL0
+repo1:
+   NPM_TOKEN: op://repo1-npm
+   CODESPACE_TOKEN:
+      ref: op://codespace
+      apps: [codespaces]
+repo2:
+   KEY2: VAL2
+`
+
+	yamlConfigurationGroups = `
+common:
+   A: common
+   B: common
+   C: common
+groups:
+   gcp:
+      B: gcp
+      C: gcp
+      variables:
+         REGION: europe-west3
+   docker:
+      C: docker
+      DOCKER_TOKEN:
+         ref: docker
+         apps: [dependabot]
+repo1:
+   groups: [gcp, docker]
+   D: repo1
+repo2:
+   groups: [docker, gcp]
+   C: repo2
+repo3:
+   KEY3: VAL3
+`
+
+	yamlConfigurationPatterns = `
+common:
+   A: common
+groups:
+   gcp:
+      GCP: gcp
+owner/*-gcp-*:
+   groups: [gcp]
+   B: pattern
+owner/explicit-gcp-setup:
+   B: explicit
+`
+
+	yamlConfigurationOptOut = `
+common:
+   AWS_ACCESS_KEY_ID: aws-id
+   AWS_SECRET_ACCESS_KEY: aws-secret
+   DOCKER_REGISTRY_TOKEN:
+      ref: docker
+      apps: [actions, dependabot]
+   SONAR_TOKEN: sonar
+   variables:
+      REGION: europe-west3
+repo1:
+   exclude: ["AWS_*", DOCKER_REGISTRY_TOKEN]
+   KEY1: VAL1
+repo2:
+   inherit: false
+   KEY2: VAL2
+repo3:
+   KEY3: VAL3
+`
+
+	yamlConfigurationTemplates = `
+common:
+   SONAR_TOKEN: op://kh-development/{{ .Repo }}/sonar
+   environments:
+      production:
+         DEPLOY_KEY: op://kh-development/{{ .Repo }}-production/{{ .Key }}
+   variables:
+      ITEM: op://{{ .Owner }}/{{ .Repo }}/item
+koenighotze/repo1:
+   KEY1: op://kh-development/shared/key1
+`
+
 	// Test constants for DumpConfiguration
 	testCommonSecretsText = "Common Secrets"
 	testRepo1Text         = "repo1:"
@@ -53,6 +195,18 @@ func TestExtractingRepositoriesFromConfiguration(t *testing.T) {
 		assert.Equal(t, 0, len(result))
 	})
 
+	t.Run("should not return the groups", func(t *testing.T) {
+		rawConfig := map[string]RepositoryConfiguration{
+			"common":    {"foo": "bar"},
+			"group:gcp": {"foo": "bar"},
+			"bar":       {"foo": "bar"},
+		}
+
+		result := extractRepositoryNamesFromConfig(rawConfig)
+
+		assert.Equal(t, []string{"bar"}, result)
+	})
+
 	t.Run("should return the repositories", func(t *testing.T) {
 		rawConfig := map[string]RepositoryConfiguration{
 			"common": {"foo": "bar"},
@@ -108,6 +262,276 @@ func TestNewConfigFromReader(t *testing.T) {
 		assert.Nil(t, result)
 	})
 
+	t.Run("should separate environment blocks from repository secrets", func(t *testing.T) {
+		reader := strings.NewReader(yamlConfigurationEnvironments)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, RepositoryConfiguration{"KEY1": "VAL1"}, result.RawConfig["repo1"])
+		assert.Equal(t, EnvironmentConfiguration{
+			"staging":    {"DB_PASSWORD": "repo1/staging"},
+			"production": {"DB_PASSWORD": "repo1/production", "DEPLOY_KEY": "repo1/production"},
+		}, result.Environments["repo1"])
+		assert.NotContains(t, result.Environments, "repo2")
+		assert.Equal(t, []string{"repo1", "repo2"}, result.Repositories)
+	})
+
+	t.Run("should return an error if a secret is not a single reference", func(t *testing.T) {
+		reader := strings.NewReader(`
+repo1:
+   KEY1:
+      nested: value
+`)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "secret KEY1 of repo1")
+		assert.Nil(t, result)
+	})
+
+	t.Run("should return an error if the environments are not a mapping", func(t *testing.T) {
+		reader := strings.NewReader(`
+repo1:
+   environments: production
+`)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "environments of repo1")
+		assert.Nil(t, result)
+	})
+
+	t.Run("should read the organizations without treating them as repository", func(t *testing.T) {
+		reader := strings.NewReader(yamlConfigurationOrganizations)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]OrganizationConfiguration{
+			"org":   {Visibility: VisibilitySelected, Secrets: RepositoryConfiguration{"SONAR_TOKEN": "op://sonar"}},
+			"other": {Visibility: VisibilityPrivate, Secrets: RepositoryConfiguration{"DOCKER_REGISTRY_TOKEN": "op://docker"}},
+		}, result.Organizations)
+		assert.Equal(t, []string{"org/repo1", "other/repo2"}, result.Repositories)
+	})
+
+	t.Run("should return an error if the visibility of an organization is invalid", func(t *testing.T) {
+		reader := strings.NewReader(`
+organizations:
+   org:
+      visibility: public
+`)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "organization org has invalid visibility public")
+		assert.Nil(t, result)
+	})
+
+	t.Run("should return an error if an organization has an unknown field", func(t *testing.T) {
+		reader := strings.NewReader(`
+organizations:
+   org:
+      SONAR_TOKEN: op://sonar
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "organization org has unknown field SONAR_TOKEN")
+	})
+
+	t.Run("should separate the variables from the repository secrets", func(t *testing.T) {
+		reader := strings.NewReader(yamlConfigurationVariables)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, RepositoryConfiguration{"KEY1": "VAL1"}, result.RawConfig["repo1"])
+		assert.Equal(t, RepositoryConfiguration{"REGION": "europe-west3"}, result.Variables["common"])
+		assert.NotContains(t, result.Variables, "repo2")
+	})
+
+	t.Run("should read the references and apps of secrets", func(t *testing.T) {
+		reader := strings.NewReader(yamlConfigurationApps)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, RepositoryConfiguration{"NPM_TOKEN": "op://npm", "KEY0": "VAL0"}, result.RawConfig["common"])
+		assert.Equal(t, SecretApps{"NPM_TOKEN": {"actions", "dependabot"}}, result.Apps["common"])
+		assert.Equal(t, SecretApps{"CODESPACE_TOKEN": {"codespaces"}}, result.Apps["repo1"])
+	})
+
+	t.Run("should return an error if a secret has an invalid app", func(t *testing.T) {
+		reader := strings.NewReader(`
+repo1:
+   KEY1:
+      ref: op://key
+      apps: [pages]
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "secret KEY1 of repo1 has invalid app pages")
+	})
+
+	t.Run("should return an error if a secret has no ref", func(t *testing.T) {
+		reader := strings.NewReader(`
+repo1:
+   KEY1:
+      apps: [dependabot]
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "secret KEY1 of repo1 has no ref")
+	})
+
+	t.Run("should read the groups and the groups included by repositories", func(t *testing.T) {
+		reader := strings.NewReader(yamlConfigurationGroups)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"repo1", "repo2", "repo3"}, result.Repositories)
+		assert.Equal(t, []string{"docker", "gcp"}, result.GetGroups())
+		assert.Equal(t, map[string][]string{"repo1": {"gcp", "docker"}, "repo2": {"docker", "gcp"}}, result.IncludedGroups)
+	})
+
+	t.Run("should return an error if a repository includes an unknown group", func(t *testing.T) {
+		reader := strings.NewReader(`
+repo1:
+   groups: [aws]
+`)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "repository repo1 includes unknown group aws")
+		assert.Nil(t, result)
+	})
+
+	t.Run("should return an error if a group includes other groups", func(t *testing.T) {
+		reader := strings.NewReader(`
+groups:
+   gcp:
+      groups: [docker]
+   docker:
+      C: docker
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "group gcp cannot include groups")
+	})
+
+	t.Run("should return an error if the common section includes groups", func(t *testing.T) {
+		reader := strings.NewReader(`
+common:
+   groups: [docker]
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "common cannot include groups")
+	})
+
+	t.Run("should not treat patterns as repositories", func(t *testing.T) {
+		reader := strings.NewReader(yamlConfigurationPatterns)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"owner/explicit-gcp-setup"}, result.Repositories)
+		assert.Equal(t, []string{"owner/*-gcp-*"}, result.Patterns)
+	})
+
+	t.Run("should return an error if the owner of a pattern is a pattern", func(t *testing.T) {
+		reader := strings.NewReader(`
+"*/repo":
+   KEY: VAL
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "pattern */repo must have the form owner/pattern")
+	})
+
+	t.Run("should return an error if a pattern is malformed", func(t *testing.T) {
+		reader := strings.NewReader(`
+"owner/[repo":
+   KEY: VAL
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "pattern owner/[repo is malformed")
+	})
+
+	t.Run("should read the opt-outs of common secrets", func(t *testing.T) {
+		reader := strings.NewReader(yamlConfigurationOptOut)
+
+		result, err := NewConfigFromReader(reader)
+
+		assert.Nil(t, err)
+		assert.Equal(t, map[string][]string{"repo1": {"AWS_*", "DOCKER_REGISTRY_TOKEN"}}, result.ExcludedCommon)
+		assert.Equal(t, map[string]bool{"repo2": true}, result.SkipCommon)
+		assert.Equal(t, RepositoryConfiguration{"KEY1": "VAL1"}, result.RawConfig["repo1"])
+	})
+
+	t.Run("should return an error if the common section opts out of itself", func(t *testing.T) {
+		reader := strings.NewReader(`
+common:
+   exclude: [KEY]
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "common cannot opt out of common secrets")
+	})
+
+	t.Run("should return an error if inherit is not a boolean", func(t *testing.T) {
+		reader := strings.NewReader(`
+repo1:
+   inherit: sometimes
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "inherit of repo1 must be true or false")
+	})
+
+	t.Run("should return an error if prune is not a boolean", func(t *testing.T) {
+		reader := strings.NewReader(`
+repo1:
+   prune: never
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "prune of repo1 must be true or false")
+	})
+
+	t.Run("should return an error if the common section switches off pruning", func(t *testing.T) {
+		reader := strings.NewReader(`
+common:
+   prune: false
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "common cannot switch off pruning, only repositories can")
+	})
+
+	t.Run("should return an error if a reader based configuration includes files", func(t *testing.T) {
+		reader := strings.NewReader(`
+include: [other.yml]
+`)
+
+		_, err := NewConfigFromReader(reader)
+
+		assert.ErrorContains(t, err, "include is only supported when reading configuration files")
+	})
+
 	t.Run("should accept any io.Reader, not only bytes.Reader", func(t *testing.T) {
 		reader := strings.NewReader(yamlConfigurationCommonOnly)
 
@@ -183,9 +607,430 @@ repo1:
 	})
 }
 
+func TestGetConfigurationForRepositoryWithGroups(t *testing.T) {
+	t.Run("should merge common, the groups in order and the repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationGroups))
+
+		result := config.GetConfigurationForRepository("repo1")
+
+		assert.Equal(t, RepositoryConfiguration{
+			"A":            "common",
+			"B":            "gcp",
+			"C":            "docker",
+			"D":            "repo1",
+			"DOCKER_TOKEN": "docker",
+		}, result)
+	})
+
+	t.Run("should let later groups override earlier ones and the repository override all", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationGroups))
+
+		result := config.GetConfigurationForRepository("repo2")
+
+		assert.Equal(t, "gcp", result["B"])
+		assert.Equal(t, "repo2", result["C"])
+	})
+
+	t.Run("should not apply groups to repositories that do not include them", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationGroups))
+
+		result := config.GetConfigurationForRepository("repo3")
+
+		assert.Equal(t, RepositoryConfiguration{"A": "common", "B": "common", "C": "common", "KEY3": "VAL3"}, result)
+	})
+
+	t.Run("should merge the apps and variables of the groups", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationGroups))
+
+		assert.Equal(t, []string{"dependabot"}, config.GetAppsForRepository("repo1")["DOCKER_TOKEN"])
+		assert.Equal(t, RepositoryConfiguration{"REGION": "europe-west3"}, config.GetVariablesForRepository("repo1"))
+		assert.Empty(t, config.GetVariablesForRepository("repo3"))
+	})
+}
+
+func TestExpandPatterns(t *testing.T) {
+	listRepositories := func(owner string) ([]string, error) {
+		return []string{owner + "/a-gcp-setup", owner + "/explicit-gcp-setup", owner + "/website"}, nil
+	}
+
+	t.Run("should add the repositories matching a pattern", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationPatterns))
+
+		err := config.ExpandPatterns(listRepositories)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/a-gcp-setup", "owner/explicit-gcp-setup"}, config.Repositories)
+		assert.Equal(t, map[string]string{"owner/a-gcp-setup": "owner/*-gcp-*"}, config.MatchedPatterns)
+	})
+
+	t.Run("should configure matched repositories with the pattern and its groups", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationPatterns))
+		_ = config.ExpandPatterns(listRepositories)
+
+		result := config.GetConfigurationForRepository("owner/a-gcp-setup")
+
+		assert.Equal(t, RepositoryConfiguration{"A": "common", "GCP": "gcp", "B": "pattern"}, result)
+	})
+
+	t.Run("should let explicit repositories take precedence over patterns", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationPatterns))
+		_ = config.ExpandPatterns(listRepositories)
+
+		result := config.GetConfigurationForRepository("owner/explicit-gcp-setup")
+
+		assert.Equal(t, RepositoryConfiguration{"A": "common", "B": "explicit"}, result)
+	})
+
+	t.Run("should list the repositories of each owner once", func(t *testing.T) {
+		config := &Configuration{Patterns: []string{"owner/a-*", "owner/b-*"}}
+		calls := 0
+
+		_ = config.ExpandPatterns(func(owner string) ([]string, error) {
+			calls++
+			return nil, nil
+		})
+
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("should return an error if a repository matches several patterns", func(t *testing.T) {
+		config := &Configuration{Patterns: []string{"owner/*-gcp-*", "owner/a-*"}}
+
+		err := config.ExpandPatterns(listRepositories)
+
+		assert.ErrorContains(t, err, "repository owner/a-gcp-setup matches the patterns owner/*-gcp-* and owner/a-*")
+	})
+
+	t.Run("should return the error if listing the repositories fails", func(t *testing.T) {
+		config := &Configuration{Patterns: []string{"owner/*"}}
+
+		err := config.ExpandPatterns(func(owner string) ([]string, error) {
+			return nil, assert.AnError
+		})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
+
+func TestGetConfigurationForRepositoryWithOptOut(t *testing.T) {
+	t.Run("should not return excluded common secrets", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationOptOut))
+
+		result := config.GetConfigurationForRepository("repo1")
+
+		assert.Equal(t, RepositoryConfiguration{"SONAR_TOKEN": "sonar", "KEY1": "VAL1"}, result)
+		assert.NotContains(t, config.GetAppsForRepository("repo1"), "DOCKER_REGISTRY_TOKEN")
+	})
+
+	t.Run("should not return anything of the common section if the repository does not inherit it", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationOptOut))
+
+		assert.Equal(t, RepositoryConfiguration{"KEY2": "VAL2"}, config.GetConfigurationForRepository("repo2"))
+		assert.Empty(t, config.GetVariablesForRepository("repo2"))
+	})
+
+	t.Run("should return all common secrets to other repositories", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationOptOut))
+
+		result := config.GetConfigurationForRepository("repo3")
+
+		assert.Len(t, result, 5)
+	})
+
+	t.Run("should exclude common environment secrets and variables", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(`
+common:
+   environments:
+      production:
+         AWS_DEPLOY_KEY: aws-deploy
+         SONAR_TOKEN: sonar
+   variables:
+      AWS_REGION: eu-central-1
+      SONAR_HOST: sonarcloud.io
+repo1:
+   exclude: ["AWS_*"]
+   environments:
+      staging:
+         AWS_DEPLOY_KEY: aws-staging
+   variables:
+      AWS_PROFILE: repo1
+`))
+
+		assert.Equal(t, EnvironmentConfiguration{
+			"production": {"SONAR_TOKEN": "sonar"},
+			"staging":    {"AWS_DEPLOY_KEY": "aws-staging"},
+		}, config.GetEnvironmentsForRepository("repo1"))
+		assert.Equal(t, RepositoryConfiguration{"SONAR_HOST": "sonarcloud.io", "AWS_PROFILE": "repo1"}, config.GetVariablesForRepository("repo1"))
+	})
+
+	t.Run("should only exclude common secrets", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(`
+repo1:
+   exclude: ["AWS_*"]
+   AWS_REGION: eu-central-1
+`))
+
+		result := config.GetConfigurationForRepository("repo1")
+
+		assert.Equal(t, RepositoryConfiguration{"AWS_REGION": "eu-central-1"}, result)
+	})
+}
+
+func TestGetConfigurationForRepositoryWithTemplates(t *testing.T) {
+	t.Run("should expand the placeholders for the repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationTemplates))
+
+		result := config.GetConfigurationForRepository("koenighotze/repo1")
+
+		assert.Equal(t, RepositoryConfiguration{
+			"SONAR_TOKEN": "op://kh-development/repo1/sonar",
+			"KEY1":        "op://kh-development/shared/key1",
+		}, result)
+	})
+
+	t.Run("should expand the placeholders of environments and variables", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationTemplates))
+
+		assert.Equal(t, EnvironmentConfiguration{
+			"production": {"DEPLOY_KEY": "op://kh-development/repo1-production/DEPLOY_KEY"},
+		}, config.GetEnvironmentsForRepository("koenighotze/repo1"))
+		assert.Equal(t, RepositoryConfiguration{"ITEM": "op://koenighotze/repo1/item"}, config.GetVariablesForRepository("koenighotze/repo1"))
+	})
+
+	t.Run("should keep the templates in the common section", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationTemplates))
+
+		assert.Equal(t, "op://kh-development/{{ .Repo }}/sonar", config.GetCommonConfiguration()["SONAR_TOKEN"])
+	})
+
+	t.Run("should return an error for unknown placeholders", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+owner/repo1:
+   KEY1: op://vault/{{ .Branch }}/key1
+`))
+
+		assert.ErrorContains(t, err, "secret KEY1 of owner/repo1 has unresolved placeholders")
+	})
+
+	t.Run("should return an error for malformed placeholders", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+owner/repo1:
+   KEY1: op://vault/{{ .Repo /key1
+`))
+
+		assert.ErrorContains(t, err, "secret KEY1 of owner/repo1 has unresolved placeholders")
+	})
+
+	t.Run("should return an error for placeholders in organization secrets", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+organizations:
+   org:
+      secrets:
+         SONAR_TOKEN: op://vault/{{ .Repo }}/sonar
+`))
+
+		assert.ErrorContains(t, err, "secret SONAR_TOKEN of organization org cannot use placeholders")
+	})
+}
+
+func TestGetCommonConfiguration(t *testing.T) {
+	t.Run("should return the common secrets", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationFull))
+
+		result := config.GetCommonConfiguration()
+
+		assert.Equal(t, RepositoryConfiguration{"KEY0": "VAL0"}, result)
+	})
+}
+
+func TestGetSuppressedCommonKeys(t *testing.T) {
+	config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationOptOut))
+
+	t.Run("should return the excluded common keys", func(t *testing.T) {
+		assert.Equal(t, []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "DOCKER_REGISTRY_TOKEN"}, config.GetSuppressedCommonKeys("repo1"))
+	})
+
+	t.Run("should return all common keys if the repository does not inherit them", func(t *testing.T) {
+		assert.Equal(t, []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "DOCKER_REGISTRY_TOKEN", "SONAR_TOKEN", "variables/REGION"}, config.GetSuppressedCommonKeys("repo2"))
+	})
+
+	t.Run("should return the excluded environment secrets and variables", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(`
+common:
+   environments:
+      production:
+         AWS_DEPLOY_KEY: aws-deploy
+   variables:
+      AWS_REGION: eu-central-1
+repo1:
+   exclude: ["AWS_*"]
+`))
+
+		assert.Equal(t, []string{"environments/production/AWS_DEPLOY_KEY", "variables/AWS_REGION"}, config.GetSuppressedCommonKeys("repo1"))
+	})
+
+	t.Run("should return nothing if the repository receives all common secrets", func(t *testing.T) {
+		assert.Empty(t, config.GetSuppressedCommonKeys("repo3"))
+	})
+}
+
+func TestGetAppsForRepository(t *testing.T) {
+	t.Run("should default to actions", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationFull))
+
+		result := config.GetAppsForRepository("repo1")
+
+		assert.Equal(t, SecretApps{"KEY0": {"actions"}, "KEY1": {"actions"}}, result)
+	})
+
+	t.Run("should use the apps of the common section", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationApps))
+
+		result := config.GetAppsForRepository("repo2")
+
+		assert.Equal(t, []string{"actions", "dependabot"}, result["NPM_TOKEN"])
+	})
+
+	t.Run("should replace the apps of common secrets overridden by the repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationApps))
+
+		result := config.GetAppsForRepository("repo1")
+
+		assert.Equal(t, SecretApps{
+			"NPM_TOKEN":       {"actions"},
+			"KEY0":            {"actions"},
+			"CODESPACE_TOKEN": {"codespaces"},
+		}, result)
+	})
+}
+
+func TestGetEnvironmentsForRepository(t *testing.T) {
+	t.Run("should merge the common environments into the repository environments", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationEnvironments))
+
+		result := config.GetEnvironmentsForRepository("repo1")
+
+		assert.Equal(t, EnvironmentConfiguration{
+			"staging":    {"DB_PASSWORD": "repo1/staging"},
+			"production": {"DB_PASSWORD": "repo1/production", "DEPLOY_KEY": "repo1/production"},
+		}, result)
+	})
+
+	t.Run("should return the common environments if the repository has none", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationEnvironments))
+
+		result := config.GetEnvironmentsForRepository("repo2")
+
+		assert.Equal(t, EnvironmentConfiguration{
+			"production": {"DEPLOY_KEY": "common/production"},
+		}, result)
+	})
+
+	t.Run("should not modify the common environments when merging", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationEnvironments))
+
+		_ = config.GetEnvironmentsForRepository("repo1")
+
+		assert.Equal(t, "common/production", config.Environments["common"]["production"]["DEPLOY_KEY"])
+	})
+
+	t.Run("should return an empty configuration if no environments are defined", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationFull))
+
+		result := config.GetEnvironmentsForRepository("repo1")
+
+		assert.Empty(t, result)
+	})
+}
+
+func TestGetVariablesForRepository(t *testing.T) {
+	t.Run("should override common variables with repository variables", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationVariables))
+
+		result := config.GetVariablesForRepository("repo1")
+
+		assert.Equal(t, RepositoryConfiguration{
+			"REGION":               "us-east1",
+			"GCP_RESOURCE_POSTFIX": "op://vault/item/postfix",
+		}, result)
+	})
+
+	t.Run("should return the common variables if the repository has none", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationVariables))
+
+		result := config.GetVariablesForRepository("repo2")
+
+		assert.Equal(t, RepositoryConfiguration{"REGION": "europe-west3"}, result)
+	})
+}
+
+func TestGetAllReferences(t *testing.T) {
+	t.Run("should return every reference once", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(`
+organizations:
+   owner:
+      secrets:
+         ORG_TOKEN: op://vault/org/token
+common:
+   SONAR_TOKEN: op://vault/{{ .Repo }}/sonar
+   variables:
+      REGION: europe-west3
+      ITEM: op://vault/item/field
+owner/repo1:
+   KEY1: env://KEY1
+   environments:
+      production:
+         DEPLOY_KEY: op://vault/org/token
+owner/repo2:
+   KEY1: env://KEY1
+`))
+
+		result := config.GetAllReferences()
+
+		assert.Equal(t, []string{
+			"op://vault/org/token",
+			"env://KEY1",
+			"op://vault/repo1/sonar",
+			"op://vault/item/field",
+			"op://vault/repo2/sonar",
+		}, result)
+	})
+}
+
+func TestGetSelectedRepositoriesForOrganization(t *testing.T) {
+	t.Run("should return the configured repositories of the organization", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationOrganizations))
+
+		result := config.GetSelectedRepositoriesForOrganization("org")
+
+		assert.Equal(t, []string{"org/repo1"}, result)
+	})
+
+	t.Run("should not match organizations sharing a prefix", func(t *testing.T) {
+		config := Configuration{Repositories: []string{"org/repo1", "organization/repo2"}}
+
+		result := config.GetSelectedRepositoriesForOrganization("org")
+
+		assert.Equal(t, []string{"org/repo1"}, result)
+	})
+
+	t.Run("should return an empty list if no repository belongs to the organization", func(t *testing.T) {
+		config := Configuration{Repositories: []string{"repo1"}}
+
+		result := config.GetSelectedRepositoriesForOrganization("org")
+
+		assert.Empty(t, result)
+	})
+}
+
+func notADirectory(name string) ([]os.DirEntry, error) {
+	return nil, syscall.ENOTDIR
+}
+
 func TestNewConfigFromFile(t *testing.T) {
 	t.Run("should return the error if reading the file fails", func(t *testing.T) {
 		client := configFileReader{
+			dirReader: notADirectory,
 			fileReader: func(name string) ([]byte, error) {
 				return nil, os.ErrExist
 			},
@@ -197,17 +1042,18 @@ func TestNewConfigFromFile(t *testing.T) {
 	})
 	t.Run("should return the configuration", func(t *testing.T) {
 		expectedConfig := &Configuration{
-			Repositories: []string{"repo1", "repo2"},
+			Repositories: []string{"owner/repo1", "owner/repo2"},
 			RawConfig: map[string]RepositoryConfiguration{
-				"common": map[string]string{"KEY0": "VAL0"},
-				"repo1":  map[string]string{"KEY1": "VAL1"},
-				"repo2":  map[string]string{"KEY2": "VAL2"},
+				"common":      map[string]string{"KEY0": "op://vault/common/key0"},
+				"owner/repo1": map[string]string{"KEY1": "op://vault/repo1/key1"},
+				"owner/repo2": map[string]string{"KEY2": "op://vault/repo2/key2"},
 			},
 		}
 
 		client := configFileReader{
+			dirReader: notADirectory,
 			fileReader: func(name string) ([]byte, error) {
-				return []byte(yamlConfigurationFull), nil
+				return []byte(yamlConfigurationValid), nil
 			},
 		}
 
@@ -222,6 +1068,7 @@ func TestNewConfigFromFile(t *testing.T) {
 
 	t.Run("should return the error if the file contains non-yaml data", func(t *testing.T) {
 		client := configFileReader{
+			dirReader: notADirectory,
 			fileReader: func(name string) ([]byte, error) {
 				return []byte("ffff"), nil
 			},
@@ -343,4 +1190,121 @@ func TestDumpConfiguration(t *testing.T) {
 		// Check merged config in repo section (common keys show up in repo config)
 		assert.Contains(t, result, "COMMON_KEY: common/path/secret") // Common key should appear in repo config
 	})
+
+	t.Run("should include the environment targets of each repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationEnvironments))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "Environment production:\n    - DEPLOY_KEY: common/production")
+		assert.Contains(t, result, "Environment staging:\n    - DB_PASSWORD: repo1/staging")
+	})
+
+	t.Run("should include the organization secrets and their visibility", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationOrganizations))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "- org (visibility selected):\n  Repositories: org/repo1\n  - SONAR_TOKEN: op://sonar")
+		assert.Contains(t, result, "- other (visibility private):\n  - DOCKER_REGISTRY_TOKEN: op://docker")
+	})
+
+	t.Run("should include the variables of each repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationVariables))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "  Variables:\n    - REGION: europe-west3")
+		assert.Contains(t, result, "- REGION: us-east1")
+	})
+
+	t.Run("should include the apps of each secret", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationApps))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "- NPM_TOKEN: op://npm [actions, dependabot]")
+		assert.Contains(t, result, "- NPM_TOKEN: op://repo1-npm [actions]")
+		assert.Contains(t, result, "- CODESPACE_TOKEN: op://codespace [codespaces]")
+	})
+
+	t.Run("should include the groups and the groups of each repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationGroups))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "Groups (applied to including repositories):\n")
+		assert.Contains(t, result, "- DOCKER_TOKEN: docker [dependabot]")
+		assert.Contains(t, result, "- repo1:\n  Groups: gcp, docker\n")
+		assert.NotContains(t, result, "- group:")
+	})
+
+	t.Run("should include the expansion of the patterns", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationPatterns))
+		_ = config.ExpandPatterns(func(owner string) ([]string, error) {
+			return []string{"owner/a-gcp-setup"}, nil
+		})
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "Repository Patterns:\n- owner/*-gcp-*: owner/a-gcp-setup\n")
+		assert.Contains(t, result, "- owner/a-gcp-setup:\n  Matched by pattern: owner/*-gcp-*\n  Groups: gcp\n")
+	})
+
+	t.Run("should show patterns without matches", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationPatterns))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "- owner/*-gcp-*: no matching repositories")
+	})
+
+	t.Run("should include the suppressed common secrets of each repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationOptOut))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "- repo1:\n  Suppressed common secrets: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, DOCKER_REGISTRY_TOKEN\n")
+		assert.Contains(t, result, "- repo2:\n  Suppressed common secrets: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, DOCKER_REGISTRY_TOKEN, SONAR_TOKEN, variables/REGION\n")
+		assert.NotContains(t, result, "- repo3:\n  Suppressed")
+	})
+	t.Run("should include the template and the expanded value of each repository", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationTemplates))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "Common Secrets (applied to all repositories):\n  - SONAR_TOKEN: op://kh-development/{{ .Repo }}/sonar [actions]\n")
+		assert.Contains(t, result, "  - SONAR_TOKEN: op://kh-development/repo1/sonar (from op://kh-development/{{ .Repo }}/sonar) [actions]\n")
+		assert.Contains(t, result, "  - KEY1: op://kh-development/shared/key1 [actions]\n")
+		assert.Contains(t, result, "    - DEPLOY_KEY: op://kh-development/repo1-production/DEPLOY_KEY (from op://kh-development/{{ .Repo }}-production/{{ .Key }})\n")
+		assert.Contains(t, result, "    - ITEM: op://koenighotze/repo1/item (from op://{{ .Owner }}/{{ .Repo }}/item)\n")
+	})
+}
+
+func TestPruneEnabled(t *testing.T) {
+	config, _ := NewConfigFromReader(strings.NewReader(`
+owner/managed-by-hand:
+   prune: false
+   KEY: op://vault/item/key
+owner/pruned:
+   prune: true
+owner/*-gcp:
+   prune: false
+`))
+	config.Repositories = append(config.Repositories, "owner/app-gcp")
+	config.MatchedPatterns = map[string]string{"owner/app-gcp": "owner/*-gcp"}
+
+	t.Run("should prune repositories by default", func(t *testing.T) {
+		assert.True(t, config.PruneEnabled("owner/pruned"))
+		assert.True(t, config.PruneEnabled("owner/other"))
+	})
+
+	t.Run("should not prune repositories that switch it off", func(t *testing.T) {
+		assert.False(t, config.PruneEnabled("owner/managed-by-hand"))
+		assert.False(t, config.PruneEnabled("owner/app-gcp"))
+	})
+
+	t.Run("should show that pruning is disabled in the dump", func(t *testing.T) {
+		assert.Contains(t, config.DumpConfiguration(), "- owner/managed-by-hand:\n  Pruning disabled\n")
+	})
 }
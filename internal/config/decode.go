@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// repositoryEntry is the decoded form of a single top level entry of the
+// configuration file, i.e. the common section or a repository.
+type repositoryEntry struct {
+	secrets      RepositoryConfiguration
+	environments EnvironmentConfiguration
+	variables    RepositoryConfiguration
+	apps         SecretApps
+	groups       []string
+	exclude      []string
+	inherit      *bool
+	prune        *bool
+}
+
+func decodeRepositoryEntry(name string, rawEntry any) (entry repositoryEntry, err error) {
+	if rawEntry == nil {
+		return entry, nil
+	}
+
+	fields, ok := rawEntry.(map[string]any)
+	if !ok {
+		return entry, fmt.Errorf("entry %s must be a mapping", name)
+	}
+
+	entry.secrets = make(RepositoryConfiguration)
+	for key, value := range fields {
+		switch key {
+		case environmentsSection:
+			entry.environments, err = decodeEnvironments(name, value)
+		case variablesSection:
+			entry.variables, err = decodeSecrets("variables of "+name, value)
+		case groupsSection:
+			entry.groups, err = decodeNameList(groupsSection, name, value)
+		case excludeField:
+			entry.exclude, err = decodeExclude(name, value)
+		case inheritField:
+			entry.inherit, err = decodeSwitch(inheritField, name, value)
+		case pruneField:
+			entry.prune, err = decodeSwitch(pruneField, name, value)
+		default:
+			err = entry.decodeSecret(name, key, value)
+		}
+		if err != nil {
+			return entry, err
+		}
+	}
+
+	return entry, nil
+}
+
+// decodeSecret decodes a repository secret. It is either a plain reference or
+// a mapping of the reference and the apps the secret is added to.
+func (entry *repositoryEntry) decodeSecret(name string, key string, value any) (err error) {
+	fields, ok := value.(map[string]any)
+	if !ok {
+		entry.secrets[key], err = decodeSecretReference(name, key, value)
+		return err
+	}
+
+	for field, fieldValue := range fields {
+		switch field {
+		case "ref":
+			entry.secrets[key], err = decodeSecretReference(name, key, fieldValue)
+		case "apps":
+			if entry.apps == nil {
+				entry.apps = make(SecretApps)
+			}
+			entry.apps[key], err = decodeApps(name, key, fieldValue)
+		default:
+			err = fmt.Errorf("secret %s of %s has unknown field %s", key, name, field)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, ok := entry.secrets[key]; !ok {
+		return fmt.Errorf("secret %s of %s has no ref", key, name)
+	}
+
+	return nil
+}
+
+func decodeNameList(field string, name string, value any) ([]string, error) {
+	rawNames, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("%s of %s must be a list", field, name)
+	}
+
+	names := make([]string, 0, len(rawNames))
+	for _, rawName := range rawNames {
+		entryName, ok := rawName.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s of %s must be a list of names", field, name)
+		}
+		names = append(names, entryName)
+	}
+
+	return names, nil
+}
+
+func decodeExclude(name string, value any) ([]string, error) {
+	exclude, err := decodeNameList(excludeField, name, value)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("exclude pattern %s of %s is malformed: %w", pattern, name, err)
+		}
+	}
+
+	return exclude, nil
+}
+
+func decodeSwitch(field string, name string, value any) (*bool, error) {
+	enabled, ok := value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%s of %s must be true or false", field, name)
+	}
+	return &enabled, nil
+}
+
+func decodeApps(name string, key string, value any) ([]string, error) {
+	rawApps, ok := value.([]any)
+	if !ok || len(rawApps) == 0 {
+		return nil, fmt.Errorf("apps of secret %s of %s must be a non-empty list", key, name)
+	}
+
+	apps := make([]string, 0, len(rawApps))
+	for _, app := range rawApps {
+		switch app {
+		case AppActions, AppDependabot, AppCodespaces:
+			if !slices.Contains(apps, app.(string)) {
+				apps = append(apps, app.(string))
+			}
+		default:
+			return nil, fmt.Errorf("secret %s of %s has invalid app %v, expected one of %s, %s or %s",
+				key, name, app, AppActions, AppDependabot, AppCodespaces)
+		}
+	}
+
+	return apps, nil
+}
+
+func decodeOrganizations(rawOrganizations any) (map[string]OrganizationConfiguration, error) {
+	if rawOrganizations == nil {
+		return nil, nil
+	}
+
+	organizations, ok := rawOrganizations.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a mapping", organizationsSection)
+	}
+
+	result := make(map[string]OrganizationConfiguration, len(organizations))
+	for organization, rawOrganization := range organizations {
+		fields, ok := rawOrganization.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("organization %s must be a mapping", organization)
+		}
+
+		orgConfig := OrganizationConfiguration{Visibility: VisibilityPrivate}
+		for key, value := range fields {
+			var err error
+			switch key {
+			case "visibility":
+				orgConfig.Visibility, err = decodeVisibility(organization, value)
+			case "secrets":
+				orgConfig.Secrets, err = decodeOrganizationSecrets(organization, value)
+			default:
+				err = fmt.Errorf("organization %s has unknown field %s", organization, key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		result[organization] = orgConfig
+	}
+
+	return result, nil
+}
+
+// decodeOrganizationSecrets decodes the secrets of an organization. They are
+// not bound to a repository, so placeholders cannot be expanded.
+func decodeOrganizationSecrets(organization string, value any) (RepositoryConfiguration, error) {
+	secrets, err := decodeSecrets("secrets of organization "+organization, value)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, reference := range secrets {
+		if IsTemplate(reference) {
+			return nil, fmt.Errorf("secret %s of organization %s cannot use placeholders", key, organization)
+		}
+	}
+
+	return secrets, nil
+}
+
+func decodeVisibility(organization string, value any) (string, error) {
+	switch value {
+	case VisibilityAll, VisibilityPrivate, VisibilitySelected:
+		return value.(string), nil
+	default:
+		return "", fmt.Errorf("organization %s has invalid visibility %v, expected one of %s, %s or %s",
+			organization, value, VisibilityAll, VisibilityPrivate, VisibilitySelected)
+	}
+}
+
+func decodeEnvironments(name string, rawEnvironments any) (EnvironmentConfiguration, error) {
+	if rawEnvironments == nil {
+		return nil, nil
+	}
+
+	environments, ok := rawEnvironments.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("environments of %s must be a mapping", name)
+	}
+
+	result := make(EnvironmentConfiguration, len(environments))
+	for environment, rawSecrets := range environments {
+		secrets, err := decodeSecrets(fmt.Sprintf("environment %s of %s", environment, name), rawSecrets)
+		if err != nil {
+			return nil, err
+		}
+		result[environment] = secrets
+	}
+
+	return result, nil
+}
+
+func decodeSecrets(name string, rawSecrets any) (RepositoryConfiguration, error) {
+	if rawSecrets == nil {
+		return RepositoryConfiguration{}, nil
+	}
+
+	fields, ok := rawSecrets.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s must be a mapping", name)
+	}
+
+	secrets := make(RepositoryConfiguration, len(fields))
+	for key, value := range fields {
+		reference, err := decodeSecretReference(name, key, value)
+		if err != nil {
+			return nil, err
+		}
+		secrets[key] = reference
+	}
+
+	return secrets, nil
+}
+
+func decodeSecretReference(name string, key string, value any) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if err := checkTemplate(key, value); err != nil {
+			return "", fmt.Errorf("secret %s of %s has unresolved placeholders, %s: %w", key, name, supportedPlaceholders, err)
+		}
+		return value, nil
+	case map[string]any, []any:
+		return "", fmt.Errorf("secret %s of %s must be a single reference", key, name)
+	default:
+		return fmt.Sprint(value), nil
+	}
+}
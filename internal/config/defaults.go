@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+func decodeDefaults(rawDefaults any) (defaults Defaults, err error) {
+	if rawDefaults == nil {
+		return defaults, nil
+	}
+
+	fields, ok := rawDefaults.(map[string]any)
+	if !ok {
+		return defaults, fmt.Errorf("%s must be a mapping", defaultsSection)
+	}
+
+	for key, value := range fields {
+		switch key {
+		case "owner":
+			owner, ok := value.(string)
+			if !ok || owner == "" || strings.Contains(owner, "/") {
+				return defaults, fmt.Errorf("owner of %s must be the name of a user or organization", defaultsSection)
+			}
+			defaults.Owner = owner
+		case "vaults":
+			defaults.Vaults, err = decodeVaultAliases(value)
+		case "never_prune":
+			defaults.NeverPrune, err = decodeNeverPrune(value)
+		default:
+			err = fmt.Errorf("%s has unknown field %s", defaultsSection, key)
+		}
+		if err != nil {
+			return defaults, err
+		}
+	}
+
+	return defaults, nil
+}
+
+func decodeVaultAliases(value any) (map[string]string, error) {
+	rawVaults, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("vaults of %s must be a mapping of aliases to vaults", defaultsSection)
+	}
+
+	vaults := make(map[string]string, len(rawVaults))
+	for alias, rawVault := range rawVaults {
+		vault, ok := rawVault.(string)
+		if !ok || vault == "" || strings.Contains(vault, "/") {
+			return nil, fmt.Errorf("vault alias %s of %s must name a single vault", alias, defaultsSection)
+		}
+		vaults[alias] = vault
+	}
+
+	return vaults, nil
+}
+
+func decodeNeverPrune(value any) ([]string, error) {
+	patterns, err := decodeNameList("never_prune", defaultsSection, value)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("never_prune pattern %s of %s is malformed: %w", pattern, defaultsSection, err)
+		}
+	}
+
+	return patterns, nil
+}
+
+// qualify prefixes repository keys without an owner with the default owner.
+func (d Defaults) qualify(name string) string {
+	if d.Owner == "" || name == commonSection || strings.Contains(name, "/") {
+		return name
+	}
+	return d.Owner + "/" + name
+}
+
+// isOnePasswordReference reports whether the value is a 1Password reference,
+// whose vault may be an alias.
+func isOnePasswordReference(value string) bool {
+	return strings.HasPrefix(value, onePasswordReferencePrefix)
+}
+
+// resolveVaultAlias replaces the vault alias of a 1Password reference with
+// the vault it stands for. Other values are returned unchanged.
+func (d Defaults) resolveVaultAlias(value string) string {
+	if !isOnePasswordReference(value) {
+		return value
+	}
+
+	vault, rest, _ := strings.Cut(strings.TrimPrefix(value, onePasswordReferencePrefix), "/")
+	if resolved, ok := d.Vaults[vault]; ok {
+		return onePasswordReferencePrefix + resolved + "/" + rest
+	}
+	return value
+}
+
+func (d Defaults) resolveVaultAliases(secrets RepositoryConfiguration) {
+	for key, value := range secrets {
+		secrets[key] = d.resolveVaultAlias(value)
+	}
+}
+
+// resolveVaultAliases resolves the vault aliases of every configured value.
+func (c *Configuration) resolveVaultAliases() {
+	if len(c.Defaults.Vaults) == 0 {
+		return
+	}
+
+	for _, secrets := range c.RawConfig {
+		c.Defaults.resolveVaultAliases(secrets)
+	}
+	for _, environments := range c.Environments {
+		for _, secrets := range environments {
+			c.Defaults.resolveVaultAliases(secrets)
+		}
+	}
+	for _, variables := range c.Variables {
+		c.Defaults.resolveVaultAliases(variables)
+	}
+	for _, organization := range c.Organizations {
+		c.Defaults.resolveVaultAliases(organization.Secrets)
+	}
+}
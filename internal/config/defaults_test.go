@@ -0,0 +1,178 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const yamlConfigurationDefaults = `
+defaults:
+   owner: koenighotze
+   vaults:
+      dev: kh-development
+common:
+   SONAR_TOKEN: op://dev/sonar/token
+   environments:
+      production:
+         DEPLOY_KEY: op://dev/{{ .Repo }}/deploy-key
+organizations:
+   koenighotze:
+      visibility: selected
+      secrets:
+         ORG_TOKEN: op://dev/org/token
+repo1:
+   KEY1: op://other/repo1/key1
+   variables:
+      ITEM: op://dev/repo1/item
+other/repo2:
+   KEY2: op://dev/repo2/key2
+`
+
+func TestDefaults(t *testing.T) {
+	t.Run("should prefix repositories without an owner with the default owner", func(t *testing.T) {
+		config, err := NewConfigFromReader(strings.NewReader(yamlConfigurationDefaults))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"koenighotze/repo1", "other/repo2"}, config.Repositories)
+		assert.Equal(t, []string{"koenighotze/repo1"}, config.GetSelectedRepositoriesForOrganization("koenighotze"))
+	})
+
+	t.Run("should prefix patterns without an owner with the default owner", func(t *testing.T) {
+		config, err := NewConfigFromReader(strings.NewReader(`
+defaults:
+   owner: koenighotze
+"*-gcp-*":
+   KEY1: op://vault/item/key1
+`))
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"koenighotze/*-gcp-*"}, config.Patterns)
+	})
+
+	t.Run("should resolve vault aliases of every value", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationDefaults))
+
+		assert.Equal(t, RepositoryConfiguration{
+			"SONAR_TOKEN": "op://kh-development/sonar/token",
+			"KEY1":        "op://other/repo1/key1",
+		}, config.GetConfigurationForRepository("koenighotze/repo1"))
+		assert.Equal(t, "op://kh-development/repo1/deploy-key", config.GetEnvironmentsForRepository("koenighotze/repo1")["production"]["DEPLOY_KEY"])
+		assert.Equal(t, RepositoryConfiguration{"ITEM": "op://kh-development/repo1/item"}, config.GetVariablesForRepository("koenighotze/repo1"))
+		assert.Equal(t, "op://kh-development/org/token", config.Organizations["koenighotze"].Secrets["ORG_TOKEN"])
+	})
+
+	t.Run("should return an error if a repository is configured with and without owner", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+defaults:
+   owner: koenighotze
+repo1:
+   KEY1: op://vault/item/key1
+koenighotze/repo1:
+   KEY2: op://vault/item/key2
+`))
+
+		assert.ErrorContains(t, err, "repository koenighotze/repo1 is configured more than once")
+	})
+
+	t.Run("should return an error for unknown fields", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+defaults:
+   vault: kh-development
+`))
+
+		assert.ErrorContains(t, err, "defaults has unknown field vault")
+	})
+
+	t.Run("should return an error if the owner is not a single name", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+defaults:
+   owner: koenighotze/repo
+`))
+
+		assert.ErrorContains(t, err, "owner of defaults must be the name of a user or organization")
+	})
+
+	t.Run("should return an error if a vault alias does not name a single vault", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+defaults:
+   vaults:
+      dev: [kh-development]
+`))
+
+		assert.ErrorContains(t, err, "vault alias dev of defaults must name a single vault")
+	})
+
+	t.Run("should protect secrets matching a never_prune pattern", func(t *testing.T) {
+		config, err := NewConfigFromReader(strings.NewReader(`
+defaults:
+   never_prune: [CODECOV_TOKEN, "MANUAL_*"]
+`))
+
+		assert.NoError(t, err)
+		assert.True(t, config.IsNeverPruned("CODECOV_TOKEN"))
+		assert.True(t, config.IsNeverPruned("MANUAL_DEPLOY_KEY"))
+		assert.False(t, config.IsNeverPruned("SONAR_TOKEN"))
+	})
+
+	t.Run("should return an error if a never_prune pattern is malformed", func(t *testing.T) {
+		_, err := NewConfigFromReader(strings.NewReader(`
+defaults:
+   never_prune: ["[A-"]
+`))
+
+		assert.ErrorContains(t, err, "never_prune pattern [A- of defaults is malformed")
+	})
+
+	t.Run("should apply defaults of another configuration file", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"configs/defaults.yml": {Data: []byte("defaults:\n  owner: koenighotze\n  vaults:\n    dev: kh-development\n")},
+			"configs/repos.yml":    {Data: []byte("repo1:\n  KEY1: op://dev/repo1/key1\n")},
+		})
+
+		config, err := reader.ReadConfiguration("configs")
+
+		assert.NoError(t, err)
+		assert.Equal(t, RepositoryConfiguration{"KEY1": "op://kh-development/repo1/key1"}, config.GetConfigurationForRepository("koenighotze/repo1"))
+	})
+
+	t.Run("should return an error if a configuration file has repositories without owner", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config.yml": {Data: []byte("repo1:\n  KEY1: op://vault/repo1/key1\n")},
+		})
+
+		_, err := reader.ReadConfiguration("config.yml")
+
+		assert.ErrorContains(t, err, "repository repo1 has no owner, use owner/repo1 or configure defaults.owner")
+	})
+
+	t.Run("should show the defaults in the dump", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader(yamlConfigurationDefaults))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "Defaults:\n- Owner: koenighotze (name expands to koenighotze/name)\n- Vault dev: op://dev/... expands to op://kh-development/...\n")
+		assert.Contains(t, result, "- koenighotze/repo1:\n")
+	})
+
+	t.Run("should show the never pruned secrets in the dump", func(t *testing.T) {
+		config, _ := NewConfigFromReader(strings.NewReader("defaults:\n   never_prune: [CODECOV_TOKEN]\n"))
+
+		result := config.DumpConfiguration()
+
+		assert.Contains(t, result, "Defaults:\n- Never pruned: CODECOV_TOKEN\n")
+	})
+}
+
+func TestIsOnePasswordReference(t *testing.T) {
+	t.Run("should detect 1Password references", func(t *testing.T) {
+		assert.True(t, isOnePasswordReference("op://vault/item/field"))
+	})
+
+	t.Run("should treat references of other schemes as no 1Password references", func(t *testing.T) {
+		assert.False(t, isOnePasswordReference("vault://secret/data/item#field"))
+		assert.False(t, isOnePasswordReference("europe-west3"))
+	})
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// fragmentLoader merges configuration fragments into a single document. It
+// remembers the file that defined each value so that conflicting definitions
+// can be reported with both sources.
+type fragmentLoader struct {
+	reader   configFileReader
+	document map[string]any
+	origins  map[string]string
+	loaded   map[string]bool
+	loading  map[string]bool
+
+	violations  []Violation
+	unqualified []Violation
+}
+
+func newFragmentLoader(reader configFileReader) *fragmentLoader {
+	return &fragmentLoader{
+		reader:   reader,
+		document: make(map[string]any),
+		origins:  make(map[string]string),
+		loaded:   make(map[string]bool),
+		loading:  make(map[string]bool),
+	}
+}
+
+func isFragment(name string) bool {
+	extension := filepath.Ext(name)
+	return extension == ".yml" || extension == ".yaml"
+}
+
+func (l *fragmentLoader) load(path string) error {
+	if entries, err := l.reader.dirReader(path); err == nil {
+		return l.loadDirectory(path, entries)
+	}
+
+	return l.loadFile(path)
+}
+
+func (l *fragmentLoader) loadDirectory(dir string, entries []os.DirEntry) error {
+	for _, entry := range entries {
+		if entry.IsDir() || !isFragment(entry.Name()) {
+			continue
+		}
+
+		if err := l.loadFile(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *fragmentLoader) loadFile(path string) error {
+	path = filepath.Clean(path)
+	if l.loading[path] {
+		return fmt.Errorf("config file %s includes itself", path)
+	}
+	if l.loaded[path] {
+		return nil
+	}
+
+	configFile, err := l.reader.fileReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	document, err := l.parse(path, configFile)
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	var includes []string
+	if rawIncludes := document[includeSection]; rawIncludes != nil {
+		if includes, err = decodeNameList(includeSection, path, rawIncludes); err != nil {
+			return err
+		}
+	}
+	delete(document, includeSection)
+
+	if err = l.merge(l.document, document, nil, path); err != nil {
+		return err
+	}
+	l.loaded[path] = true
+
+	l.loading[path] = true
+	defer delete(l.loading, path)
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		if err = l.load(include); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parse decodes the content of a configuration file and validates it on the
+// way, as only the syntax tree knows the positions of the values.
+func (l *fragmentLoader) parse(path string, content []byte) (document map[string]any, err error) {
+	file, err := parser.ParseBytes(content, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Docs) == 0 || file.Docs[0].Body == nil {
+		return nil, nil
+	}
+
+	body := file.Docs[0].Body
+	if err = yaml.NodeToValue(body, &document); err != nil {
+		return nil, err
+	}
+
+	validator := validator{file: path}
+	validator.validateDocument(body)
+	l.violations = append(l.violations, validator.violations...)
+	l.unqualified = append(l.unqualified, validator.unqualified...)
+
+	return document, nil
+}
+
+// merge adds the values of the source to the target. Mappings are merged
+// recursively, any other value may only be defined once.
+func (l *fragmentLoader) merge(target map[string]any, source map[string]any, parents []string, file string) error {
+	for key, value := range source {
+		path := append(parents[:len(parents):len(parents)], key)
+		existing, found := target[key]
+
+		switch {
+		case !found || existing == nil:
+			target[key] = value
+			l.origins[originKey(path)] = file
+			continue
+		case value == nil:
+			continue
+		}
+
+		existingMapping, existingIsMapping := existing.(map[string]any)
+		mapping, isMapping := value.(map[string]any)
+		if existingIsMapping && isMapping {
+			if err := l.merge(existingMapping, mapping, path, file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		return fmt.Errorf("conflicting configuration: %s is defined in %s and %s",
+			strings.Join(path, " > "), l.originOf(path), file)
+	}
+
+	return nil
+}
+
+// checkOwners reports the repository keys without owner if no fragment sets
+// the default owner, which is only known once every fragment is merged.
+func (l *fragmentLoader) checkOwners() {
+	defaults, err := decodeDefaults(l.document[defaultsSection])
+	if err == nil && defaults.Owner == "" {
+		// A malformed defaults block is reported when decoding the document.
+		l.violations = append(l.violations, l.unqualified...)
+	}
+}
+
+// checkQualifiedRepositories reports repository keys that only differ by the
+// default owner, e.g. repo1 in one fragment and owner/repo1 in another. They
+// name the same repository once qualified, so both files are reported.
+func (l *fragmentLoader) checkQualifiedRepositories() error {
+	defaults, err := decodeDefaults(l.document[defaultsSection])
+	if err != nil || defaults.Owner == "" {
+		// A malformed defaults block is reported when decoding the document.
+		return nil
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(l.document)) {
+		switch name {
+		case defaultsSection, organizationsSection, groupsSection, includeSection:
+			continue
+		}
+
+		qualified := defaults.qualify(name)
+		if _, found := l.document[qualified]; found && qualified != name {
+			return fmt.Errorf("conflicting configuration: repository %s is defined in %s as %s and in %s as %s",
+				qualified, l.originOf([]string{name}), name, l.originOf([]string{qualified}), qualified)
+		}
+	}
+
+	return nil
+}
+
+// originOf returns the file that defined the value at the path or the
+// closest of its parents.
+func (l *fragmentLoader) originOf(path []string) string {
+	for length := len(path); length > 0; length-- {
+		if origin, ok := l.origins[originKey(path[:length])]; ok {
+			return origin
+		}
+	}
+	return "unknown file"
+}
+
+func originKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
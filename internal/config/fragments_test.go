@@ -0,0 +1,148 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createFragmentReader(files fstest.MapFS) configFileReader {
+	return configFileReader{
+		fileReader: func(name string) ([]byte, error) {
+			return fs.ReadFile(files, name)
+		},
+		dirReader: func(name string) ([]os.DirEntry, error) {
+			return fs.ReadDir(files, name)
+		},
+	}
+}
+
+func TestReadConfigurationFromFragments(t *testing.T) {
+	t.Run("should merge all fragments of a directory", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/common.yml":   {Data: []byte("common:\n  KEY0: op://vault/item/key0\n")},
+			"config/repo1.yaml":   {Data: []byte("owner/repo1:\n  KEY1: op://vault/item/key1\n")},
+			"config/repo2.yml":    {Data: []byte("owner/repo2:\n  KEY2: op://vault/item/key2\n")},
+			"config/README.md":    {Data: []byte("not a fragment")},
+			"config/nested/x.yml": {Data: []byte("owner/repo3:\n  KEY3: op://vault/item/key3\n")},
+		})
+
+		result, err := reader.ReadConfiguration("config")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/repo1", "owner/repo2"}, result.Repositories)
+		assert.Equal(t, RepositoryConfiguration{"KEY0": "op://vault/item/key0", "KEY1": "op://vault/item/key1"}, result.GetConfigurationForRepository("owner/repo1"))
+	})
+
+	t.Run("should merge the keys of a repository defined in several fragments", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("owner/repo1:\n  KEY1: op://vault/item/key1\n  variables:\n    A: a\n")},
+			"config/b.yml": {Data: []byte("owner/repo1:\n  KEY2: op://vault/item/key2\n  variables:\n    B: b\n")},
+		})
+
+		result, err := reader.ReadConfiguration("config")
+
+		assert.NoError(t, err)
+		assert.Equal(t, RepositoryConfiguration{"KEY1": "op://vault/item/key1", "KEY2": "op://vault/item/key2"}, result.RawConfig["owner/repo1"])
+		assert.Equal(t, RepositoryConfiguration{"A": "a", "B": "b"}, result.Variables["owner/repo1"])
+	})
+
+	t.Run("should report conflicting definitions with both source files", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("owner/repo1:\n  KEY1: op://vault/item/key1\n")},
+			"config/b.yml": {Data: []byte("owner/repo1:\n  KEY1: op://vault/other/key1\n")},
+		})
+
+		_, err := reader.ReadConfiguration("config")
+
+		assert.ErrorContains(t, err, "owner/repo1 > KEY1 is defined in config/a.yml and config/b.yml")
+	})
+
+	t.Run("should report a repository configured with and without the default owner with both source files", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("defaults:\n  owner: owner\nrepo1:\n  KEY1: op://vault/item/key1\n")},
+			"config/b.yml": {Data: []byte("owner/repo1:\n  KEY2: op://vault/item/key2\n")},
+		})
+
+		_, err := reader.ReadConfiguration("config")
+
+		assert.EqualError(t, err, "conflicting configuration: repository owner/repo1 is defined in config/a.yml as repo1 and in config/b.yml as owner/repo1")
+	})
+
+	t.Run("should read the included files relative to the including file", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config.yml":         {Data: []byte("include: [teams/platform.yml, shared]\ncommon:\n  KEY0: op://vault/item/key0\n")},
+			"teams/platform.yml": {Data: []byte("owner/repo1:\n  KEY1: op://vault/item/key1\n")},
+			"shared/groups.yml":  {Data: []byte("groups:\n  gcp:\n    GCP: op://vault/gcp/token\n")},
+			"shared/repo2.yml":   {Data: []byte("owner/repo2:\n  groups: [gcp]\n")},
+		})
+
+		result, err := reader.ReadConfiguration("config.yml")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/repo1", "owner/repo2"}, result.Repositories)
+		assert.Equal(t, RepositoryConfiguration{"KEY0": "op://vault/item/key0", "GCP": "op://vault/gcp/token"}, result.GetConfigurationForRepository("owner/repo2"))
+	})
+
+	t.Run("should read a file included several times only once", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("include: [b.yml]\nowner/repo1:\n  KEY1: op://vault/item/key1\n")},
+			"config/b.yml": {Data: []byte("owner/repo2:\n  KEY2: op://vault/item/key2\n")},
+		})
+
+		result, err := reader.ReadConfiguration("config")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/repo1", "owner/repo2"}, result.Repositories)
+	})
+
+	t.Run("should return an error if files include each other", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"a.yml": {Data: []byte("include: [b.yml]\n")},
+			"b.yml": {Data: []byte("include: [a.yml]\n")},
+		})
+
+		_, err := reader.ReadConfiguration("a.yml")
+
+		assert.ErrorContains(t, err, "config file a.yml includes itself")
+	})
+
+	t.Run("should return an error naming the file that cannot be parsed", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("ffff")},
+		})
+
+		_, err := reader.ReadConfiguration("config")
+
+		assert.ErrorContains(t, err, "failed to parse config file config/a.yml")
+	})
+
+	t.Run("should accept empty fragments", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("# nothing yet\n")},
+			"config/b.yml": {Data: []byte("owner/repo1:\n  KEY1: op://vault/item/key1\n")},
+		})
+
+		result, err := reader.ReadConfiguration("config")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/repo1"}, result.Repositories)
+	})
+}
+
+func TestReadConfigurationFromDirectory(t *testing.T) {
+	t.Run("should read the fragments of a directory on disk", func(t *testing.T) {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "common.yml"), []byte("common:\n  KEY0: op://vault/item/key0\n"), 0o600))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "repo1.yml"), []byte("owner/repo1:\n  KEY1: op://vault/item/key1\n"), 0o600))
+
+		result, err := NewConfigFileReader().ReadConfiguration(dir)
+
+		assert.NoError(t, err)
+		assert.Equal(t, RepositoryConfiguration{"KEY0": "op://vault/item/key0", "KEY1": "op://vault/item/key1"}, result.GetConfigurationForRepository("owner/repo1"))
+	})
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"text/template"
+)
+
+// templateData holds the placeholders that can be used in values. They are
+// filled in per repository, so a single common value can point every
+// repository at its own 1Password item.
+type templateData struct {
+	Repo  string
+	Owner string
+	Key   string
+}
+
+func newTemplateData(repository string, key string) templateData {
+	owner, repo, ok := strings.Cut(repository, "/")
+	if !ok {
+		return templateData{Repo: repository, Key: key}
+	}
+	return templateData{Repo: repo, Owner: owner, Key: key}
+}
+
+// IsTemplate reports whether the value contains placeholders that are
+// expanded per repository.
+func IsTemplate(value string) bool {
+	return strings.Contains(value, "{{")
+}
+
+func expandTemplate(value string, data templateData) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(value)
+	if err != nil {
+		return "", err
+	}
+
+	var expanded strings.Builder
+	if err := tmpl.Execute(&expanded, data); err != nil {
+		return "", err
+	}
+	return expanded.String(), nil
+}
+
+// supportedPlaceholders names the placeholders in errors about values that
+// use others.
+const supportedPlaceholders = "only {{ .Repo }}, {{ .Owner }} and {{ .Key }} are supported"
+
+// checkTemplate makes sure every placeholder of the value can be resolved,
+// so expanding it per repository cannot fail later on.
+func checkTemplate(key string, value string) error {
+	if !IsTemplate(value) {
+		return nil
+	}
+	_, err := expandTemplate(value, newTemplateData("owner/repository", key))
+	return err
+}
+
+// expandSecrets returns a copy of the secrets with the placeholders expanded
+// for the repository.
+func expandSecrets(secrets RepositoryConfiguration, repository string) RepositoryConfiguration {
+	expanded := make(RepositoryConfiguration, len(secrets))
+	for key, value := range secrets {
+		expanded[key] = value
+		if !IsTemplate(value) {
+			continue
+		}
+		// Templates are checked while loading, so expanding cannot fail.
+		if result, err := expandTemplate(value, newTemplateData(repository, key)); err == nil {
+			expanded[key] = result
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/goccy/go-yaml/ast"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
+)
+
+var (
+	secretNamePattern       = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+	repositoryKeyPattern    = regexp.MustCompile(`^([A-Za-z0-9-]+/)?[A-Za-z0-9._*?\[\]-]+$`)
+	organizationNamePattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+)
+
+const reservedSecretPrefix = "GITHUB_"
+
+// Violation is a single rule violation found while validating a
+// configuration file.
+type Violation struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", v.File, v.Line, v.Column, v.Message)
+}
+
+// ValidationError reports every violation found in the configuration.
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "invalid configuration, found %d violation(s):", len(e.Violations))
+	for _, violation := range e.Violations {
+		builder.WriteString("\n  ")
+		builder.WriteString(violation.String())
+	}
+	return builder.String()
+}
+
+// validator checks a parsed configuration file against the rules GitHub
+// imposes on secrets and the form of secret references.
+type validator struct {
+	file       string
+	violations []Violation
+	// unqualified holds the repository keys without owner. They are only
+	// violations if no fragment sets the default owner.
+	unqualified []Violation
+}
+
+func (v *validator) violation(node ast.Node, format string, args ...any) Violation {
+	position := node.GetToken().Position
+	return Violation{
+		File:    v.file,
+		Line:    position.Line,
+		Column:  position.Column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func (v *validator) report(node ast.Node, format string, args ...any) {
+	v.violations = append(v.violations, v.violation(node, format, args...))
+}
+
+func mappingPairs(node ast.Node) ([]*ast.MappingValueNode, bool) {
+	switch node := node.(type) {
+	case *ast.MappingNode:
+		return node.Values, true
+	case *ast.MappingValueNode:
+		return []*ast.MappingValueNode{node}, true
+	default:
+		return nil, false
+	}
+}
+
+func keyOf(pair *ast.MappingValueNode) string {
+	return pair.Key.GetToken().Value
+}
+
+func isNull(node ast.Node) bool {
+	_, null := node.(*ast.NullNode)
+	return node == nil || null
+}
+
+func (v *validator) validateDocument(body ast.Node) {
+	pairs, _ := mappingPairs(body)
+	for _, pair := range pairs {
+		switch key := keyOf(pair); key {
+		case includeSection, defaultsSection:
+		case organizationsSection:
+			v.validateOrganizations(pair.Value)
+		case groupsSection:
+			groups, _ := mappingPairs(pair.Value)
+			for _, group := range groups {
+				v.validateEntry(group.Value)
+			}
+		case commonSection:
+			v.validateEntry(pair.Value)
+		default:
+			switch {
+			case !repositoryKeyPattern.MatchString(key):
+				v.report(pair.Key, "repository %s must have the form owner/name or name", key)
+			case !strings.Contains(key, "/"):
+				v.unqualified = append(v.unqualified, v.violation(pair.Key,
+					"repository %s has no owner, use owner/%s or configure %s.owner", key, key, defaultsSection))
+			}
+			v.validateEntry(pair.Value)
+		}
+	}
+}
+
+func (v *validator) validateOrganizations(node ast.Node) {
+	organizations, _ := mappingPairs(node)
+	for _, organization := range organizations {
+		if name := keyOf(organization); !organizationNamePattern.MatchString(name) {
+			v.report(organization.Key, "organization %s is not a valid organization name", name)
+		}
+
+		fields, _ := mappingPairs(organization.Value)
+		for _, field := range fields {
+			switch keyOf(field) {
+			case "secrets":
+				v.validateSecrets(field.Value)
+			case "visibility":
+				v.validateVisibility(organization, field.Value)
+			default:
+				v.report(field.Key, "organization %s has unknown field %s", keyOf(organization), keyOf(field))
+			}
+		}
+	}
+}
+
+func (v *validator) validateEntry(node ast.Node) {
+	pairs, _ := mappingPairs(node)
+	names := make(map[string]bool)
+
+	for _, pair := range pairs {
+		switch keyOf(pair) {
+		case environmentsSection:
+			environments, _ := mappingPairs(pair.Value)
+			for _, environment := range environments {
+				v.validateSecrets(environment.Value)
+			}
+		case variablesSection:
+			v.validateVariables(pair.Value)
+		case groupsSection, excludeField, inheritField, pruneField:
+		default:
+			v.validateName(pair, names)
+			v.validateSecretValue(pair, true)
+		}
+	}
+}
+
+func (v *validator) validateSecrets(node ast.Node) {
+	pairs, _ := mappingPairs(node)
+	names := make(map[string]bool)
+
+	for _, pair := range pairs {
+		v.validateName(pair, names)
+		v.validateSecretValue(pair, false)
+	}
+}
+
+func (v *validator) validateVariables(node ast.Node) {
+	pairs, _ := mappingPairs(node)
+	names := make(map[string]bool)
+
+	for _, pair := range pairs {
+		v.validateName(pair, names)
+		value, ok := pair.Value.(*ast.StringNode)
+		switch {
+		case !ok:
+		case provider.IsReference(value.Value):
+			v.validateReference(pair, value)
+		default:
+			v.validateTemplate(pair, value)
+		}
+	}
+}
+
+func (v *validator) validateName(pair *ast.MappingValueNode, names map[string]bool) {
+	name := keyOf(pair)
+
+	switch {
+	case !secretNamePattern.MatchString(name):
+		v.report(pair.Key, "name %s must only contain upper case letters, digits and underscores and must not start with a digit", name)
+	case strings.HasPrefix(name, reservedSecretPrefix):
+		v.report(pair.Key, "name %s must not start with %s", name, reservedSecretPrefix)
+	}
+
+	normalized := strings.ToUpper(name)
+	if names[normalized] {
+		v.report(pair.Key, "name %s is defined more than once, names are case-insensitive", name)
+	}
+	names[normalized] = true
+}
+
+// validateSecretValue checks the reference of a secret. Secrets of
+// repositories may also be a mapping of the reference and their apps.
+func (v *validator) validateSecretValue(pair *ast.MappingValueNode, withApps bool) {
+	value := pair.Value
+	if fields, ok := mappingPairs(value); ok {
+		if !withApps {
+			v.report(pair.Key, "secret %s must be a single reference", keyOf(pair))
+			return
+		}
+
+		value = nil
+		for _, field := range fields {
+			switch keyOf(field) {
+			case "ref":
+				value = field.Value
+			case "apps":
+				v.validateApps(pair, field)
+			default:
+				v.report(field.Key, "secret %s has unknown field %s", keyOf(pair), keyOf(field))
+			}
+		}
+		if value == nil {
+			v.report(pair.Key, "secret %s has no ref", keyOf(pair))
+			return
+		}
+	}
+
+	if isNull(value) {
+		v.report(pair.Key, "secret %s has no reference", keyOf(pair))
+		return
+	}
+
+	reference, ok := value.(*ast.StringNode)
+	if !ok {
+		v.report(value, "secret %s must be a reference, got %s", keyOf(pair), value.GetToken().Value)
+		return
+	}
+	v.validateReference(pair, reference)
+}
+
+func (v *validator) validateApps(pair *ast.MappingValueNode, field *ast.MappingValueNode) {
+	apps, ok := field.Value.(*ast.SequenceNode)
+	if !ok || len(apps.Values) == 0 {
+		v.report(field.Key, "apps of secret %s must be a non-empty list", keyOf(pair))
+		return
+	}
+
+	for _, app := range apps.Values {
+		switch name := app.GetToken().Value; name {
+		case AppActions, AppDependabot, AppCodespaces:
+		default:
+			v.report(app, "secret %s has invalid app %s, expected one of %s, %s or %s",
+				keyOf(pair), name, AppActions, AppDependabot, AppCodespaces)
+		}
+	}
+}
+
+func (v *validator) validateVisibility(organization *ast.MappingValueNode, value ast.Node) {
+	switch visibility := value.GetToken().Value; visibility {
+	case VisibilityAll, VisibilityPrivate, VisibilitySelected:
+	default:
+		v.report(value, "organization %s has invalid visibility %s, expected one of %s, %s or %s",
+			keyOf(organization), visibility, VisibilityAll, VisibilityPrivate, VisibilitySelected)
+	}
+}
+
+func (v *validator) validateReference(pair *ast.MappingValueNode, reference *ast.StringNode) {
+	if !v.validateTemplate(pair, reference) {
+		return
+	}
+	if err := provider.ValidateReference(reference.Value); err != nil {
+		v.report(reference, "%s of %s %v", reference.Value, keyOf(pair), err)
+	}
+}
+
+// validateTemplate reports placeholders of the value that cannot be expanded
+// per repository.
+func (v *validator) validateTemplate(pair *ast.MappingValueNode, value *ast.StringNode) bool {
+	if err := checkTemplate(keyOf(pair), value.Value); err != nil {
+		v.report(value, "secret %s has unresolved placeholders, %s: %v", keyOf(pair), supportedPlaceholders, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validateConfiguration(t *testing.T, content string) []Violation {
+	reader := createFragmentReader(fstest.MapFS{
+		"config.yml": {Data: []byte(content)},
+	})
+
+	_, err := reader.ReadConfiguration("config.yml")
+	if err == nil {
+		return nil
+	}
+
+	validationError, ok := err.(*ValidationError)
+	assert.True(t, ok, "Expected a validation error, got %v", err)
+	return validationError.Violations
+}
+
+func TestValidateConfiguration(t *testing.T) {
+	t.Run("should accept a valid configuration", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+common:
+  SONAR_TOKEN: op://vault/sonar/token
+organizations:
+  owner:
+    secrets:
+      ORG_TOKEN: op://vault/org/section/token
+groups:
+  gcp:
+    GCP_KEY: op://vault/gcp/key
+owner/repo.name-1:
+  groups: [gcp]
+  exclude: ["AWS_*"]
+  NPM_TOKEN:
+    ref: op://vault/npm/token
+    apps: [dependabot]
+  variables:
+    REGION: europe-west3
+  environments:
+    production:
+      DB_PASSWORD: op://vault/db/password?attribute=otp
+owner/*-gcp-*:
+  _UNDERSCORE: op://vault/item/field
+  FROM_ENV: env://NPM_TOKEN
+  FROM_FILE: file:///run/secrets/token
+  FROM_LITERAL: literal:not-a-secret
+  TEMPLATED: op://vault/{{ .Repo }}/{{ .Key }}
+owner/no-secrets:
+`)
+
+		assert.Empty(t, violations)
+	})
+
+	t.Run("should accept the configuration of the repository", func(t *testing.T) {
+		_, err := NewConfigFileReader().ReadConfiguration("../../config.yml")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should report invalid secret names with their position", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+owner/repo:
+  lower_case: op://vault/item/field
+  1STARTS_WITH_DIGIT: op://vault/item/field
+`)
+
+		assert.Equal(t, []Violation{
+			{File: "config.yml", Line: 3, Column: 3, Message: "name lower_case must only contain upper case letters, digits and underscores and must not start with a digit"},
+			{File: "config.yml", Line: 4, Column: 3, Message: "name 1STARTS_WITH_DIGIT must only contain upper case letters, digits and underscores and must not start with a digit"},
+		}, violations)
+	})
+
+	t.Run("should report secret names with the GITHUB_ prefix", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+common:
+  GITHUB_TOKEN: op://vault/item/field
+`)
+
+		assert.Equal(t, []Violation{
+			{File: "config.yml", Line: 3, Column: 3, Message: "name GITHUB_TOKEN must not start with GITHUB_"},
+		}, violations)
+	})
+
+	t.Run("should report names that are only unique case-sensitively", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+owner/repo:
+  environments:
+    production:
+      TOKEN: op://vault/item/field
+      Token: op://vault/item/field
+`)
+
+		assert.Len(t, violations, 2)
+		assert.Equal(t, "name Token is defined more than once, names are case-insensitive", violations[1].Message)
+		assert.Equal(t, 6, violations[1].Line)
+	})
+
+	t.Run("should report repository keys that are not owner/name pairs or names", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+not a name:
+  TOKEN: op://vault/item/field
+owner/name/extra:
+  TOKEN: op://vault/item/field
+`)
+
+		assert.Equal(t, []Violation{
+			{File: "config.yml", Line: 2, Column: 1, Message: "repository not a name must have the form owner/name or name"},
+			{File: "config.yml", Line: 4, Column: 1, Message: "repository owner/name/extra must have the form owner/name or name"},
+		}, violations)
+	})
+
+	t.Run("should report every repository without owner with its position", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+repo1:
+  KEY1: op://vault/repo1/key1
+owner/repo2:
+  KEY2: op://vault/repo2/key2
+repo3:
+  KEY3: op://vault/repo3/key3
+`)
+
+		assert.Equal(t, []Violation{
+			{File: "config.yml", Line: 2, Column: 1, Message: "repository repo1 has no owner, use owner/repo1 or configure defaults.owner"},
+			{File: "config.yml", Line: 6, Column: 1, Message: "repository repo3 has no owner, use owner/repo3 or configure defaults.owner"},
+		}, violations)
+	})
+
+	t.Run("should accept repositories without owner if another fragment sets the default owner", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("repo1:\n  KEY1: op://vault/repo1/key1\n")},
+			"config/b.yml": {Data: []byte("defaults:\n  owner: owner\n")},
+		})
+
+		config, err := reader.ReadConfiguration("config")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/repo1"}, config.Repositories)
+	})
+
+	t.Run("should report malformed references", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+owner/repo:
+  NO_SCHEME: vault/item/field
+  UNKNOWN_SCHEME: https://example.com/token
+  TOO_SHORT: op://vault/item
+  TOO_LONG: op://vault/item/section/field/extra
+  NUMBER: 42
+  EMPTY:
+  MAPPED:
+    ref: op:/vault/item/field
+  variables:
+    LITERAL: anything goes
+    REFERENCE: op://vault
+`)
+
+		messages := make([]string, 0, len(violations))
+		for _, violation := range violations {
+			messages = append(messages, violation.Message)
+		}
+		assert.Equal(t, []string{
+			"vault/item/field of NO_SCHEME has an unknown scheme, expected one of aws-sm://, aws-ssm://, env://, file://, literal:, op://, vault://",
+			"https://example.com/token of UNKNOWN_SCHEME has an unknown scheme, expected one of aws-sm://, aws-ssm://, env://, file://, literal:, op://, vault://",
+			"op://vault/item of TOO_SHORT is not a reference of the form op://vault/item[/section]/field",
+			"op://vault/item/section/field/extra of TOO_LONG is not a reference of the form op://vault/item[/section]/field",
+			"secret NUMBER must be a reference, got 42",
+			"secret EMPTY has no reference",
+			"op:/vault/item/field of MAPPED is not a reference of the form op://vault/item[/section]/field",
+			"op://vault of REFERENCE is not a reference of the form op://vault/item[/section]/field",
+		}, messages)
+	})
+
+	t.Run("should report violations of organizations and groups", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+organizations:
+  not/an-org:
+    secrets:
+      token: op://vault/item/field
+groups:
+  docker:
+    DOCKER_TOKEN: docker
+`)
+
+		assert.Len(t, violations, 3)
+	})
+
+	t.Run("should report malformed secret mappings with their position", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+owner/repo:
+  NO_REF:
+    apps: [actions]
+  UNKNOWN_FIELD:
+    ref: op://vault/item/field
+    value: op://vault/item/field
+  INVALID_APP:
+    ref: op://vault/item/field
+    apps: [actions, pages]
+  NO_APPS:
+    ref: op://vault/item/field
+    apps: []
+  environments:
+    production:
+      MAPPED:
+        ref: op://vault/item/field
+`)
+
+		assert.Equal(t, []Violation{
+			{File: "config.yml", Line: 3, Column: 3, Message: "secret NO_REF has no ref"},
+			{File: "config.yml", Line: 7, Column: 5, Message: "secret UNKNOWN_FIELD has unknown field value"},
+			{File: "config.yml", Line: 10, Column: 21, Message: "secret INVALID_APP has invalid app pages, expected one of actions, dependabot or codespaces"},
+			{File: "config.yml", Line: 13, Column: 5, Message: "apps of secret NO_APPS must be a non-empty list"},
+			{File: "config.yml", Line: 16, Column: 7, Message: "secret MAPPED must be a single reference"},
+		}, violations)
+	})
+
+	t.Run("should report unknown placeholders with their position", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+owner/repo:
+  UNKNOWN: op://vault/{{ .Foo }}/field
+  variables:
+    LITERAL: "{{ .Branch }}"
+`)
+
+		assert.Len(t, violations, 2)
+		assert.Equal(t, []int{3, 12}, []int{violations[0].Line, violations[0].Column})
+		assert.Contains(t, violations[0].Message, "secret UNKNOWN has unresolved placeholders, only {{ .Repo }}, {{ .Owner }} and {{ .Key }} are supported")
+		assert.Equal(t, []int{5, 14}, []int{violations[1].Line, violations[1].Column})
+		assert.Contains(t, violations[1].Message, "secret LITERAL has unresolved placeholders")
+	})
+
+	t.Run("should report invalid fields of organizations with their position", func(t *testing.T) {
+		violations := validateConfiguration(t, `
+organizations:
+  owner:
+    visibility: public
+    members: [someone]
+`)
+
+		assert.Equal(t, []Violation{
+			{File: "config.yml", Line: 4, Column: 17, Message: "organization owner has invalid visibility public, expected one of all, private or selected"},
+			{File: "config.yml", Line: 5, Column: 5, Message: "organization owner has unknown field members"},
+		}, violations)
+	})
+
+	t.Run("should report the violations of every fragment before failing", func(t *testing.T) {
+		reader := createFragmentReader(fstest.MapFS{
+			"config/a.yml": {Data: []byte("owner/a:\n  token: op://vault/item/field\n")},
+			"config/b.yml": {Data: []byte("owner/b:\n  TOKEN: not-a-reference\n")},
+		})
+
+		_, err := reader.ReadConfiguration("config")
+
+		assert.ErrorContains(t, err, "found 2 violation(s)")
+		assert.ErrorContains(t, err, "config/a.yml:2:3: name token")
+		assert.ErrorContains(t, err, "config/b.yml:2:10: not-a-reference of TOKEN")
+	})
+}
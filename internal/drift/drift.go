@@ -0,0 +1,131 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+// Secret is a secret that exists in a repository.
+type Secret struct {
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Report compares the configured Actions secrets of a repository with those
+// existing in GitHub. Missing secrets are configured but do not exist, extra
+// secrets exist but are not configured.
+type Report struct {
+	Repository string   `json:"repository"`
+	Missing    []string `json:"missing"`
+	Extra      []Secret `json:"extra"`
+	Present    []Secret `json:"present"`
+}
+
+// HasDrift reports whether the repository differs from the configuration.
+func (r Report) HasDrift() bool {
+	return len(r.Missing) > 0 || len(r.Extra) > 0
+}
+
+// ConfiguredSecrets returns the names of the secrets the configuration adds
+// to Actions of the repository. Secrets only added to other apps are left out,
+// as they are not listed with the Actions secrets.
+func ConfiguredSecrets(configuration *config.Configuration, repository string) []string {
+	apps := configuration.GetAppsForRepository(repository)
+	names := make([]string, 0)
+	for _, name := range slices.Sorted(maps.Keys(configuration.GetConfigurationForRepository(repository))) {
+		if targetApps, ok := apps[name]; ok && !slices.Contains(targetApps, config.AppActions) {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Compare sorts the configured and existing secrets of the repository into
+// missing, extra and present ones.
+func Compare(repository string, configured []string, existing []github.Secret) Report {
+	report := Report{
+		Repository: repository,
+		Missing:    make([]string, 0),
+		Extra:      make([]Secret, 0),
+		Present:    make([]Secret, 0),
+	}
+
+	existingNames := make(map[string]bool, len(existing))
+	for _, secret := range existing {
+		existingNames[secret.Name] = true
+		if slices.Contains(configured, secret.Name) {
+			report.Present = append(report.Present, Secret(secret))
+		} else {
+			report.Extra = append(report.Extra, Secret(secret))
+		}
+	}
+	for _, name := range configured {
+		if !existingNames[name] {
+			report.Missing = append(report.Missing, name)
+		}
+	}
+
+	sortByName := func(a, b Secret) int { return strings.Compare(a.Name, b.Name) }
+	slices.SortFunc(report.Extra, sortByName)
+	slices.SortFunc(report.Present, sortByName)
+	slices.Sort(report.Missing)
+
+	return report
+}
+
+// HasDrift reports whether at least one repository differs from the
+// configuration.
+func HasDrift(reports []Report) bool {
+	return slices.ContainsFunc(reports, Report.HasDrift)
+}
+
+// WriteText writes the reports in a human-readable form, one line per secret.
+func WriteText(writer io.Writer, reports []Report) error {
+	drifted := 0
+	for _, report := range reports {
+		if report.HasDrift() {
+			drifted++
+		}
+
+		if _, err := fmt.Fprintf(writer, "%s:\n", report.Repository); err != nil {
+			return err
+		}
+		for _, name := range report.Missing {
+			if _, err := fmt.Fprintf(writer, "  missing  %s\n", name); err != nil {
+				return err
+			}
+		}
+		for _, secret := range report.Extra {
+			if _, err := fmt.Fprintf(writer, "  extra    %s (updated %s)\n", secret.Name, secret.UpdatedAt.Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+		for _, secret := range report.Present {
+			if _, err := fmt.Fprintf(writer, "  present  %s (updated %s)\n", secret.Name, secret.UpdatedAt.Format(time.RFC3339)); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(writer, "%d of %d repositories drifted\n", drifted, len(reports))
+	return err
+}
+
+// WriteJSON writes the reports as a JSON document.
+func WriteJSON(writer io.Writer, reports []Report) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Repositories []Report `json:"repositories"`
+		Drift        bool     `json:"drift"`
+	}{reports, HasDrift(reports)})
+}
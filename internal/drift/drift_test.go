@@ -0,0 +1,103 @@
+package drift
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+var updatedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestConfiguredSecrets(t *testing.T) {
+	t.Run("should return the merged Actions secrets of the repository", func(t *testing.T) {
+		configuration, err := config.NewConfigFromReader(strings.NewReader(`
+common:
+   NPM_TOKEN:
+      ref: op://vault/npm/token
+      apps: [actions, dependabot]
+   SONAR_TOKEN: op://vault/sonar/token
+owner/repo:
+   CODESPACE_TOKEN:
+      ref: op://vault/codespace/token
+      apps: [codespaces]
+   API_KEY: op://vault/api/key
+`))
+		assert.NoError(t, err)
+
+		result := ConfiguredSecrets(configuration, "owner/repo")
+
+		assert.Equal(t, []string{"API_KEY", "NPM_TOKEN", "SONAR_TOKEN"}, result)
+	})
+}
+
+func TestCompare(t *testing.T) {
+	t.Run("should sort the secrets into missing, extra and present ones", func(t *testing.T) {
+		existing := []github.Secret{
+			{Name: "REMOVED", UpdatedAt: updatedAt},
+			{Name: "API_KEY", UpdatedAt: updatedAt},
+		}
+
+		result := Compare("owner/repo", []string{"API_KEY", "NEW_KEY"}, existing)
+
+		assert.Equal(t, Report{
+			Repository: "owner/repo",
+			Missing:    []string{"NEW_KEY"},
+			Extra:      []Secret{{Name: "REMOVED", UpdatedAt: updatedAt}},
+			Present:    []Secret{{Name: "API_KEY", UpdatedAt: updatedAt}},
+		}, result)
+		assert.True(t, result.HasDrift())
+	})
+
+	t.Run("should not report drift if all secrets are present", func(t *testing.T) {
+		result := Compare("owner/repo", []string{"API_KEY"}, []github.Secret{{Name: "API_KEY", UpdatedAt: updatedAt}})
+
+		assert.False(t, result.HasDrift())
+		assert.False(t, HasDrift([]Report{result}))
+	})
+}
+
+func TestWriteText(t *testing.T) {
+	t.Run("should write a line per secret and a summary", func(t *testing.T) {
+		var out bytes.Buffer
+		reports := []Report{
+			Compare("owner/a", []string{"API_KEY", "NEW_KEY"}, []github.Secret{{Name: "API_KEY", UpdatedAt: updatedAt}, {Name: "REMOVED", UpdatedAt: updatedAt}}),
+			Compare("owner/b", []string{"API_KEY"}, []github.Secret{{Name: "API_KEY", UpdatedAt: updatedAt}}),
+		}
+
+		err := WriteText(&out, reports)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `owner/a:
+  missing  NEW_KEY
+  extra    REMOVED (updated 2026-01-02T03:04:05Z)
+  present  API_KEY (updated 2026-01-02T03:04:05Z)
+owner/b:
+  present  API_KEY (updated 2026-01-02T03:04:05Z)
+1 of 2 repositories drifted
+`, out.String())
+	})
+}
+
+func TestWriteJSON(t *testing.T) {
+	t.Run("should write the reports and whether they drifted", func(t *testing.T) {
+		var out bytes.Buffer
+		reports := []Report{Compare("owner/a", []string{"NEW_KEY"}, nil)}
+
+		err := WriteJSON(&out, reports)
+
+		assert.NoError(t, err)
+		var document struct {
+			Repositories []Report `json:"repositories"`
+			Drift        bool     `json:"drift"`
+		}
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &document))
+		assert.True(t, document.Drift)
+		assert.Equal(t, reports, document.Repositories)
+	})
+}
@@ -0,0 +1,222 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// MaxSecretsPerRepository is the number of secrets GitHub allows per
+// repository and app.
+const MaxSecretsPerRepository = 100
+
+// Finding is a single problem a rule found in the configuration.
+type Finding struct {
+	Rule       string   `json:"rule"`
+	Severity   Severity `json:"severity"`
+	Repository string   `json:"repository,omitempty"`
+	Message    string   `json:"message"`
+}
+
+// Rule checks the configuration for one kind of problem. Check only fills in
+// the repository and message of its findings, Run adds the rest.
+type Rule struct {
+	Name     string
+	Severity Severity
+	Check    func(configuration *config.Configuration) []Finding
+}
+
+// DefaultRules returns every rule the lint command runs.
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: "shadowed-common-secret", Severity: SeverityWarning, Check: checkShadowedCommonSecrets},
+		{Name: "empty-repository", Severity: SeverityWarning, Check: checkEmptyRepositories},
+		{Name: "reference-with-conflicting-names", Severity: SeverityWarning, Check: checkReferencesWithConflictingNames},
+		{Name: "secret-limit", Severity: SeverityError, Check: checkSecretLimit},
+	}
+}
+
+// Run applies the rules to the configuration and returns the findings in
+// the order of the rules.
+func Run(configuration *config.Configuration, rules []Rule) []Finding {
+	findings := make([]Finding, 0)
+	for _, rule := range rules {
+		for _, finding := range rule.Check(configuration) {
+			finding.Rule = rule.Name
+			finding.Severity = rule.Severity
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// HasErrors reports whether at least one finding is an error.
+func HasErrors(findings []Finding) bool {
+	return slices.ContainsFunc(findings, func(finding Finding) bool {
+		return finding.Severity == SeverityError
+	})
+}
+
+// WriteText writes the findings in a human-readable form.
+func WriteText(writer io.Writer, findings []Finding) error {
+	errors := 0
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			errors++
+		}
+
+		location := ""
+		if finding.Repository != "" {
+			location = finding.Repository + ": "
+		}
+		if _, err := fmt.Fprintf(writer, "%s: %s%s [%s]\n", finding.Severity, location, finding.Message, finding.Rule); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(writer, "%d error(s), %d warning(s)\n", errors, len(findings)-errors)
+	return err
+}
+
+// WriteJSON writes the findings as a JSON document.
+func WriteJSON(writer io.Writer, findings []Finding) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Findings []Finding `json:"findings"`
+	}{findings})
+}
+
+// lintedRepositories returns the explicit repositories and the patterns, as
+// linting never expands patterns against GitHub.
+func lintedRepositories(configuration *config.Configuration) []string {
+	return append(slices.Clone(configuration.Repositories), configuration.Patterns...)
+}
+
+func checkShadowedCommonSecrets(configuration *config.Configuration) []Finding {
+	findings := make([]Finding, 0)
+	common := configuration.GetCommonConfiguration()
+
+	for _, repository := range lintedRepositories(configuration) {
+		if configuration.SkipCommon[repository] {
+			continue
+		}
+		for _, key := range slices.Sorted(maps.Keys(configuration.RawConfig[repository])) {
+			if reference, ok := common[key]; ok && reference == configuration.RawConfig[repository][key] {
+				findings = append(findings, Finding{
+					Repository: repository,
+					Message:    fmt.Sprintf("%s only repeats the common value %s", key, reference),
+				})
+			}
+		}
+	}
+
+	return findings
+}
+
+func checkEmptyRepositories(configuration *config.Configuration) []Finding {
+	findings := make([]Finding, 0)
+
+	for _, repository := range lintedRepositories(configuration) {
+		if len(configuration.RawConfig[repository]) > 0 ||
+			len(configuration.Environments[repository]) > 0 ||
+			len(configuration.Variables[repository]) > 0 ||
+			len(configuration.IncludedGroups[repository]) > 0 ||
+			len(configuration.ExcludedCommon[repository]) > 0 ||
+			configuration.SkipCommon[repository] {
+			continue
+		}
+
+		findings = append(findings, Finding{
+			Repository: repository,
+			Message:    "entry is empty, the repository only receives common secrets",
+		})
+	}
+
+	return findings
+}
+
+func checkReferencesWithConflictingNames(configuration *config.Configuration) []Finding {
+	names := make(map[string]map[string]bool)
+	addSecrets := func(secrets config.RepositoryConfiguration) {
+		for key, reference := range secrets {
+			// Templates like {{ .Key }} expand to different references per name.
+			if config.IsTemplate(reference) {
+				continue
+			}
+			if names[reference] == nil {
+				names[reference] = make(map[string]bool)
+			}
+			names[reference][key] = true
+		}
+	}
+
+	for _, secrets := range configuration.RawConfig {
+		addSecrets(secrets)
+	}
+	for _, environments := range configuration.Environments {
+		for _, secrets := range environments {
+			addSecrets(secrets)
+		}
+	}
+	for _, organization := range configuration.Organizations {
+		addSecrets(organization.Secrets)
+	}
+
+	findings := make([]Finding, 0)
+	for _, reference := range slices.Sorted(maps.Keys(names)) {
+		if len(names[reference]) > 1 {
+			findings = append(findings, Finding{
+				Message: fmt.Sprintf("%s is used under the names %s", reference, strings.Join(slices.Sorted(maps.Keys(names[reference])), ", ")),
+			})
+		}
+	}
+
+	return findings
+}
+
+func checkSecretLimit(configuration *config.Configuration) []Finding {
+	findings := make([]Finding, 0)
+
+	for _, repository := range lintedRepositories(configuration) {
+		secretsPerApp := make(map[string]int)
+		for _, apps := range configuration.GetAppsForRepository(repository) {
+			for _, app := range apps {
+				secretsPerApp[app]++
+			}
+		}
+
+		for _, app := range slices.Sorted(maps.Keys(secretsPerApp)) {
+			if secretsPerApp[app] > MaxSecretsPerRepository {
+				findings = append(findings, Finding{
+					Repository: repository,
+					Message:    fmt.Sprintf("%d %s secrets exceed the limit of %d secrets per repository", secretsPerApp[app], app, MaxSecretsPerRepository),
+				})
+			}
+		}
+
+		environments := configuration.GetEnvironmentsForRepository(repository)
+		for _, environment := range slices.Sorted(maps.Keys(environments)) {
+			if secrets := environments[environment]; len(secrets) > MaxSecretsPerRepository {
+				findings = append(findings, Finding{
+					Repository: repository,
+					Message:    fmt.Sprintf("%d secrets of environment %s exceed the limit of %d secrets per environment", len(secrets), environment, MaxSecretsPerRepository),
+				})
+			}
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,194 @@
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"koenighotze.de/github-distribute-secrets/internal/config"
+)
+
+func readConfiguration(t *testing.T, content string) *config.Configuration {
+	configuration, err := config.NewConfigFromReader(strings.NewReader(content))
+	assert.NoError(t, err)
+	return configuration
+}
+
+func findRule(name string) Rule {
+	for _, rule := range DefaultRules() {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	panic("no such rule " + name)
+}
+
+func TestShadowedCommonSecrets(t *testing.T) {
+	rule := findRule("shadowed-common-secret")
+
+	t.Run("should report repository secrets repeating the common value", func(t *testing.T) {
+		configuration := readConfiguration(t, `
+common:
+  TOKEN: op://vault/item/token
+  OTHER: op://vault/item/other
+owner/repo:
+  TOKEN: op://vault/item/token
+  OTHER: op://vault/item/different
+`)
+
+		findings := Run(configuration, []Rule{rule})
+
+		assert.Equal(t, []Finding{{
+			Rule:       "shadowed-common-secret",
+			Severity:   SeverityWarning,
+			Repository: "owner/repo",
+			Message:    "TOKEN only repeats the common value op://vault/item/token",
+		}}, findings)
+	})
+
+	t.Run("should not report repositories that do not inherit the common section", func(t *testing.T) {
+		configuration := readConfiguration(t, `
+common:
+  TOKEN: op://vault/item/token
+owner/repo:
+  inherit: false
+  TOKEN: op://vault/item/token
+`)
+
+		assert.Empty(t, Run(configuration, []Rule{rule}))
+	})
+}
+
+func TestEmptyRepositories(t *testing.T) {
+	rule := findRule("empty-repository")
+
+	t.Run("should report repositories without configuration of their own", func(t *testing.T) {
+		configuration := readConfiguration(t, `
+common:
+  TOKEN: op://vault/item/token
+owner/empty:
+owner/excluding:
+  exclude: [TOKEN]
+owner/grouped:
+  groups: [docker]
+owner/configured:
+  OTHER: op://vault/item/other
+groups:
+  docker:
+    DOCKER_TOKEN: op://vault/docker/token
+`)
+
+		findings := Run(configuration, []Rule{rule})
+
+		assert.Len(t, findings, 1)
+		assert.Equal(t, "owner/empty", findings[0].Repository)
+	})
+}
+
+func TestReferencesWithConflictingNames(t *testing.T) {
+	rule := findRule("reference-with-conflicting-names")
+
+	t.Run("should report references used under different names", func(t *testing.T) {
+		configuration := readConfiguration(t, `
+common:
+  DOCKER_TOKEN: op://vault/docker/token
+owner/repo:
+  REGISTRY_TOKEN: op://vault/docker/token
+  environments:
+    production:
+      DOCKER_TOKEN: op://vault/docker/token
+owner/other:
+  DOCKER_TOKEN: op://vault/docker/token
+  UNIQUE: op://vault/unique/token
+`)
+
+		findings := Run(configuration, []Rule{rule})
+
+		assert.Len(t, findings, 1)
+		assert.Equal(t, "op://vault/docker/token is used under the names DOCKER_TOKEN, REGISTRY_TOKEN", findings[0].Message)
+	})
+
+	t.Run("should ignore templated references", func(t *testing.T) {
+		configuration := readConfiguration(t, `
+common:
+  SONAR_TOKEN: op://vault/{{ .Repo }}/{{ .Key }}
+owner/repo:
+  NPM_TOKEN: op://vault/{{ .Repo }}/{{ .Key }}
+`)
+
+		assert.Empty(t, Run(configuration, []Rule{rule}))
+	})
+}
+
+func TestSecretLimit(t *testing.T) {
+	rule := findRule("secret-limit")
+
+	createConfiguration := func(count int, apps string) string {
+		var builder strings.Builder
+		builder.WriteString("owner/repo:\n")
+		for i := range count {
+			fmt.Fprintf(&builder, "  KEY_%d:\n    ref: op://vault/item/key%d\n    apps: [%s]\n", i, i, apps)
+		}
+		return builder.String()
+	}
+
+	t.Run("should accept repositories within the limit", func(t *testing.T) {
+		configuration := readConfiguration(t, createConfiguration(MaxSecretsPerRepository, "actions, dependabot"))
+
+		assert.Empty(t, Run(configuration, []Rule{rule}))
+	})
+
+	t.Run("should report every app of a repository over the limit as error", func(t *testing.T) {
+		configuration := readConfiguration(t, createConfiguration(MaxSecretsPerRepository+1, "actions, dependabot"))
+
+		findings := Run(configuration, []Rule{rule})
+
+		assert.Len(t, findings, 2)
+		assert.Equal(t, SeverityError, findings[0].Severity)
+		assert.Equal(t, "101 actions secrets exceed the limit of 100 secrets per repository", findings[0].Message)
+		assert.True(t, HasErrors(findings))
+	})
+}
+
+func TestHasErrors(t *testing.T) {
+	t.Run("should ignore warnings", func(t *testing.T) {
+		assert.False(t, HasErrors([]Finding{{Severity: SeverityWarning}}))
+	})
+}
+
+func TestWriteFindings(t *testing.T) {
+	findings := []Finding{
+		{Rule: "empty-repository", Severity: SeverityWarning, Repository: "owner/repo", Message: "entry is empty"},
+		{Rule: "reference-with-conflicting-names", Severity: SeverityWarning, Message: "op://x is used twice"},
+		{Rule: "secret-limit", Severity: SeverityError, Repository: "owner/big", Message: "too many"},
+	}
+
+	t.Run("should write the findings as text", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		err := WriteText(&buffer, findings)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `warning: owner/repo: entry is empty [empty-repository]
+warning: op://x is used twice [reference-with-conflicting-names]
+error: owner/big: too many [secret-limit]
+1 error(s), 2 warning(s)
+`, buffer.String())
+	})
+
+	t.Run("should write the findings as JSON", func(t *testing.T) {
+		var buffer bytes.Buffer
+
+		err := WriteJSON(&buffer, findings)
+
+		var result struct {
+			Findings []Finding `json:"findings"`
+		}
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(buffer.Bytes(), &result))
+		assert.Equal(t, findings, result.Findings)
+	})
+}
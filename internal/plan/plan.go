@@ -0,0 +1,442 @@
+package plan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/drift"
+	"koenighotze.de/github-distribute-secrets/internal/state"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
+)
+
+const planVersion = 1
+
+// Kind tells what applying an action does.
+type Kind string
+
+const (
+	Create    Kind = "create"
+	Update    Kind = "update"
+	Unchanged Kind = "unchanged"
+	Delete    Kind = "delete"
+)
+
+// Scopes of an action, telling which of its fields name the target.
+const (
+	ScopeRepository   = "repository"
+	ScopeEnvironment  = "environment"
+	ScopeOrganization = "organization"
+	ScopeVariable     = "variable"
+)
+
+// Reasons of the actions.
+const (
+	reasonNotInGithub   = "not in GitHub"
+	reasonNotWritten    = "not written by this tool before"
+	reasonChanged       = "value changed since it was last written"
+	reasonUnknown       = "exists in GitHub, but was not written by this tool before"
+	reasonForced        = "forced"
+	reasonSame          = "value is the one last written"
+	reasonNotConfigured = "not configured"
+)
+
+// Action is a single change of the plan. It never contains a value, only the
+// fingerprint of the value, so the plan file can be reviewed and kept.
+// UpdatedAt is the time an existing Actions secret was last updated.
+type Action struct {
+	Kind         Kind       `json:"action"`
+	Scope        string     `json:"scope"`
+	Repository   string     `json:"repository,omitempty"`
+	App          string     `json:"app,omitempty"`
+	Environment  string     `json:"environment,omitempty"`
+	Organization string     `json:"organization,omitempty"`
+	Visibility   string     `json:"visibility,omitempty"`
+	Repositories []string   `json:"repositories,omitempty"`
+	Name         string     `json:"name"`
+	Reason       string     `json:"reason"`
+	Fingerprint  string     `json:"fingerprint,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+
+	value string
+}
+
+// Target names the value changed by the action as in the state.
+func (a Action) Target() string {
+	switch a.Scope {
+	case ScopeEnvironment:
+		return state.EnvironmentTarget(a.Repository, a.Environment, a.Name)
+	case ScopeOrganization:
+		return state.OrganizationTarget(a.Organization, a.Name)
+	case ScopeVariable:
+		return state.VariableTarget(a.Repository, a.Name)
+	default:
+		return state.SecretTarget(a.Repository, a.App, a.Name)
+	}
+}
+
+// differsFrom describes how the action differs from the planned one, or
+// returns an empty string if both are the same.
+func (a Action) differsFrom(planned Action) string {
+	switch {
+	case a.Kind != planned.Kind:
+		return fmt.Sprintf("%s was planned to %s, but now is to %s", a.Target(), planned.Kind, a.Kind)
+	case a.Fingerprint != planned.Fingerprint:
+		return fmt.Sprintf("value of %s changed", a.Target())
+	case !sameTime(a.UpdatedAt, planned.UpdatedAt):
+		return fmt.Sprintf("%s was updated in GitHub", a.Target())
+	case a.Visibility != planned.Visibility || !slices.Equal(a.Repositories, planned.Repositories):
+		return fmt.Sprintf("repositories sharing %s changed", a.Target())
+	}
+	return ""
+}
+
+func sameTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Plan lists the actions applying the configuration takes. Configuration is
+// the fingerprint of the configuration the plan was made from.
+type Plan struct {
+	Version       int      `json:"version"`
+	Configuration string   `json:"configuration"`
+	Prune         bool     `json:"prune"`
+	Force         bool     `json:"force"`
+	Actions       []Action `json:"actions"`
+}
+
+// Fingerprint returns a hash of the configuration. It is taken before the
+// repository patterns are expanded, expanded repositories show up as
+// changed actions instead.
+func Fingerprint(configuration *config.Configuration) (string, error) {
+	content, err := json.Marshal(configuration)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint the configuration: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// planner collects the actions of a plan while reading every configured value.
+type planner struct {
+	ctx     context.Context
+	secrets provider.Provider
+	store   *state.State
+	force   bool
+	actions []Action
+}
+
+// Build plans applying the expanded configuration. Every value is read to
+// compare its fingerprint with the state, Actions secrets are also looked up
+// in GitHub. Unlike applying, a value that cannot be read fails the plan.
+func Build(ctx context.Context, configuration *config.Configuration, fingerprint string, secrets provider.Provider, gh github.GithubClient, store *state.State, prune bool, force bool) (*Plan, error) {
+	p := &planner{ctx: ctx, secrets: secrets, store: store, force: force, actions: make([]Action, 0)}
+
+	if err := p.planOrganizations(configuration); err != nil {
+		return nil, err
+	}
+	for _, repository := range configuration.Repositories {
+		if err := p.planRepository(configuration, repository, gh, prune); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Plan{
+		Version:       planVersion,
+		Configuration: fingerprint,
+		Prune:         prune,
+		Force:         force,
+		Actions:       p.actions,
+	}, nil
+}
+
+// read reads the value of the action from its reference.
+func (p *planner) read(action Action, reference string) (string, error) {
+	value, err := p.secrets.GetSecret(p.ctx, reference)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the value of %s: %w", action.Target(), err)
+	}
+	return value, nil
+}
+
+// add decides whether the value of the action must be written. known tells
+// whether GitHub was asked for the value, which exists if updatedAt is set.
+func (p *planner) add(action Action, value string, updatedAt *time.Time, known bool) {
+	stateValue := value
+	if action.Scope == ScopeOrganization {
+		stateValue = state.OrganizationValue(value, action.Visibility, action.Repositories)
+	}
+
+	target := action.Target()
+	_, recorded := p.store.Fingerprints[target]
+	action.value = value
+	action.Fingerprint = p.store.Fingerprint(target, stateValue)
+	action.UpdatedAt = updatedAt
+
+	switch {
+	case known && updatedAt == nil:
+		action.Kind, action.Reason = Create, reasonNotInGithub
+	case !known && !recorded:
+		action.Kind, action.Reason = Create, reasonNotWritten
+	case p.force:
+		action.Kind, action.Reason = Update, reasonForced
+	case p.store.Unchanged(target, stateValue):
+		action.Kind, action.Reason = Unchanged, reasonSame
+	case recorded:
+		action.Kind, action.Reason = Update, reasonChanged
+	default:
+		action.Kind, action.Reason = Update, reasonUnknown
+	}
+
+	p.actions = append(p.actions, action)
+}
+
+func (p *planner) planOrganizations(configuration *config.Configuration) error {
+	for _, organization := range slices.Sorted(maps.Keys(configuration.Organizations)) {
+		orgConfig := configuration.Organizations[organization]
+		var repositories []string
+		if orgConfig.Visibility == config.VisibilitySelected {
+			repositories = configuration.GetSelectedRepositoriesForOrganization(organization)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(orgConfig.Secrets)) {
+			action := Action{Scope: ScopeOrganization, Organization: organization, Visibility: orgConfig.Visibility, Repositories: repositories, Name: key}
+			value, err := p.read(action, orgConfig.Secrets[key])
+			if err != nil {
+				return err
+			}
+			p.add(action, value, nil, false)
+		}
+	}
+	return nil
+}
+
+func (p *planner) planRepository(configuration *config.Configuration, repository string, gh github.GithubClient, prune bool) error {
+	existing, err := gh.ListSecrets(p.ctx, repository)
+	if err != nil {
+		return fmt.Errorf("failed to list secrets of repository %s: %w", repository, err)
+	}
+	updatedAt := make(map[string]*time.Time, len(existing))
+	for _, secret := range existing {
+		updatedAt[secret.Name] = &secret.UpdatedAt
+	}
+
+	secrets := configuration.GetConfigurationForRepository(repository)
+	apps := configuration.GetAppsForRepository(repository)
+	for _, key := range slices.Sorted(maps.Keys(secrets)) {
+		targetApps, found := apps[key]
+		if !found {
+			targetApps = []string{config.AppActions}
+		}
+
+		for _, app := range targetApps {
+			action := Action{Scope: ScopeRepository, Repository: repository, App: app, Name: key}
+			value, err := p.read(action, secrets[key])
+			if err != nil {
+				return err
+			}
+			p.add(action, value, updatedAt[key], app == config.AppActions)
+		}
+	}
+
+	environments := configuration.GetEnvironmentsForRepository(repository)
+	for _, environment := range slices.Sorted(maps.Keys(environments)) {
+		for _, key := range slices.Sorted(maps.Keys(environments[environment])) {
+			action := Action{Scope: ScopeEnvironment, Repository: repository, Environment: environment, Name: key}
+			value, err := p.read(action, environments[environment][key])
+			if err != nil {
+				return err
+			}
+			p.add(action, value, nil, false)
+		}
+	}
+
+	variables := configuration.GetVariablesForRepository(repository)
+	for _, name := range slices.Sorted(maps.Keys(variables)) {
+		action := Action{Scope: ScopeVariable, Repository: repository, Name: name}
+		value := variables[name]
+		if provider.IsReference(value) {
+			if value, err = p.read(action, value); err != nil {
+				return err
+			}
+		}
+		p.add(action, value, nil, false)
+	}
+
+	if !prune || !configuration.PruneEnabled(repository) {
+		return nil
+	}
+	report := drift.Compare(repository, drift.ConfiguredSecrets(configuration, repository), existing)
+	for _, secret := range report.Extra {
+		if configuration.IsNeverPruned(secret.Name) {
+			continue
+		}
+		p.actions = append(p.actions, Action{
+			Kind:       Delete,
+			Scope:      ScopeRepository,
+			Repository: repository,
+			App:        config.AppActions,
+			Name:       secret.Name,
+			Reason:     reasonNotConfigured,
+			UpdatedAt:  &secret.UpdatedAt,
+		})
+	}
+	return nil
+}
+
+// Errors telling why a plan cannot be applied any more. Both mean that the
+// plan has to be made again.
+var (
+	ErrConfigurationChanged = errors.New("configuration changed since planning")
+	ErrRemoteChanged        = errors.New("remote state changed since planning")
+)
+
+// Verify checks that the plan still is the planned one, i.e. that neither
+// the configuration nor a value, GitHub or the state changed in between.
+func (p *Plan) Verify(planned *Plan) error {
+	if p.Configuration != planned.Configuration {
+		return ErrConfigurationChanged
+	}
+
+	current := make(map[string]Action, len(p.Actions))
+	for _, action := range p.Actions {
+		current[action.Target()] = action
+	}
+	for _, action := range planned.Actions {
+		now, ok := current[action.Target()]
+		if !ok {
+			return fmt.Errorf("%w: %s is not to %s any more", ErrRemoteChanged, action.Target(), action.Kind)
+		}
+		if difference := now.differsFrom(action); difference != "" {
+			return fmt.Errorf("%w: %s", ErrRemoteChanged, difference)
+		}
+		delete(current, action.Target())
+	}
+	for _, action := range p.Actions {
+		if _, ok := current[action.Target()]; ok {
+			return fmt.Errorf("%w: %s was not planned, but now is to %s", ErrRemoteChanged, action.Target(), action.Kind)
+		}
+	}
+
+	return nil
+}
+
+// Count returns the number of actions of the kind.
+func (p *Plan) Count(kind Kind) int {
+	count := 0
+	for _, action := range p.Actions {
+		if action.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func (p *Plan) String() string {
+	return fmt.Sprintf("%d to create, %d to update, %d to delete, %d unchanged", p.Count(Create), p.Count(Update), p.Count(Delete), p.Count(Unchanged))
+}
+
+// Apply executes the actions of a plan made by Build and records the written
+// values in the state. It stops starting new actions once the context is
+// cancelled and returns whether every action succeeded.
+func (p *Plan) Apply(ctx context.Context, gh github.GithubClient, store *state.State) (ok bool) {
+	ok = true
+	for index, action := range p.Actions {
+		if ctx.Err() != nil {
+			log.Printf("Interrupted, not applying the remaining %d actions", len(p.Actions)-index)
+			return false
+		}
+		if action.Kind == Unchanged {
+			continue
+		}
+
+		if err := action.apply(ctx, gh); err != nil {
+			log.Printf("Error applying %s of %s: %v", action.Kind, action.Target(), err)
+			ok = false
+			continue
+		}
+		if action.Kind == Delete {
+			store.Forget(action.Target())
+		} else {
+			store.Fingerprints[action.Target()] = action.Fingerprint
+		}
+	}
+	return ok
+}
+
+func (a Action) apply(ctx context.Context, gh github.GithubClient) error {
+	if a.Kind == Delete {
+		return gh.DeleteSecret(ctx, a.Name, a.Repository)
+	}
+
+	switch a.Scope {
+	case ScopeEnvironment:
+		return gh.AddSecretToEnvironment(ctx, a.Name, a.value, a.Environment, a.Repository)
+	case ScopeOrganization:
+		return gh.AddSecretToOrganization(ctx, a.Name, a.value, a.Organization, a.Visibility, a.Repositories)
+	case ScopeVariable:
+		return gh.AddVariableToRepository(ctx, a.Name, a.value, a.Repository)
+	default:
+		if a.App == config.AppActions {
+			return gh.AddSecretToRepository(ctx, a.Name, a.value, a.Repository)
+		}
+		return gh.AddSecretToApp(ctx, a.Name, a.value, a.App, a.Repository)
+	}
+}
+
+// WriteText writes the plan in a human-readable form, one line per action.
+func (p *Plan) WriteText(writer io.Writer) error {
+	for _, action := range p.Actions {
+		if _, err := fmt.Fprintf(writer, "  %-9s  %s (%s)\n", action.Kind, action.Target(), action.Reason); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(writer, "Plan: %s\n", p)
+	return err
+}
+
+// Save writes the plan file. It holds no values, but fingerprints of them,
+// so it is only readable by its owner.
+func (p *Plan) Save(path string) error {
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("failed to write plan file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Read reads a plan file written by Save.
+func Read(path string) (*Plan, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan file %s: %w", path, err)
+	}
+
+	plan := &Plan{}
+	if err = json.Unmarshal(content, plan); err != nil {
+		return nil, fmt.Errorf("plan file %s is malformed: %w", path, err)
+	}
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("plan file %s has unsupported version %d", path, plan.Version)
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,354 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/internal/state"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+// mapProvider returns the value of a reference from a map.
+type mapProvider map[string]string
+
+func (m mapProvider) GetSecret(ctx context.Context, reference string) (string, error) {
+	value, ok := m[reference]
+	if !ok {
+		return "", assert.AnError
+	}
+	return value, nil
+}
+
+func createConfiguration(t *testing.T, content string) *config.Configuration {
+	configuration, err := config.NewConfigFromReader(strings.NewReader(content))
+	assert.NoError(t, err)
+	return configuration
+}
+
+func createState(t *testing.T) *state.State {
+	store, err := state.Load(filepath.Join(t.TempDir(), "state"))
+	assert.NoError(t, err)
+	return store
+}
+
+func kinds(plan *Plan) map[string]Kind {
+	result := make(map[string]Kind, len(plan.Actions))
+	for _, action := range plan.Actions {
+		result[action.Target()] = action.Kind
+	}
+	return result
+}
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	secrets := mapProvider{"op://vault/api/key": "secret", "op://vault/new/key": "new"}
+
+	t.Run("should create Actions secrets missing in GitHub", func(t *testing.T) {
+		configuration := createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/api/key\n")
+
+		plan, err := Build(ctx, configuration, "config", secrets, &github.MockGithubClient{}, createState(t), false, false)
+
+		assert.NoError(t, err)
+		assert.Len(t, plan.Actions, 1)
+		assert.Equal(t, Create, plan.Actions[0].Kind)
+		assert.Equal(t, reasonNotInGithub, plan.Actions[0].Reason)
+		assert.Nil(t, plan.Actions[0].UpdatedAt)
+	})
+
+	t.Run("should tell unchanged from changed secrets by the state", func(t *testing.T) {
+		configuration := createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/api/key\n  NEW_KEY: op://vault/new/key\n  OTHER_KEY: op://vault/new/key\n")
+		gh := &github.MockGithubClient{Secrets: map[string][]github.Secret{"owner/repo": {
+			{Name: "API_KEY", UpdatedAt: updatedAt},
+			{Name: "NEW_KEY", UpdatedAt: updatedAt},
+			{Name: "OTHER_KEY", UpdatedAt: updatedAt},
+		}}}
+		store := createState(t)
+		store.Record("owner/repo/actions/API_KEY", "secret")
+		store.Record("owner/repo/actions/NEW_KEY", "old")
+
+		plan, err := Build(ctx, configuration, "config", secrets, gh, store, false, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]Kind{
+			"owner/repo/actions/API_KEY":   Unchanged,
+			"owner/repo/actions/NEW_KEY":   Update,
+			"owner/repo/actions/OTHER_KEY": Update,
+		}, kinds(plan))
+		assert.Equal(t, reasonChanged, plan.Actions[1].Reason)
+		assert.Equal(t, reasonUnknown, plan.Actions[2].Reason)
+		assert.True(t, updatedAt.Equal(*plan.Actions[0].UpdatedAt))
+	})
+
+	t.Run("should update unchanged secrets when forced", func(t *testing.T) {
+		configuration := createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/api/key\n")
+		gh := &github.MockGithubClient{Secrets: map[string][]github.Secret{"owner/repo": {{Name: "API_KEY", UpdatedAt: updatedAt}}}}
+		store := createState(t)
+		store.Record("owner/repo/actions/API_KEY", "secret")
+
+		plan, err := Build(ctx, configuration, "config", secrets, gh, store, false, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, Update, plan.Actions[0].Kind)
+		assert.Equal(t, reasonForced, plan.Actions[0].Reason)
+	})
+
+	t.Run("should plan values GitHub is not asked for by the state", func(t *testing.T) {
+		configuration := createConfiguration(t, `
+organizations:
+  org:
+    secrets:
+      ORG_KEY: op://vault/api/key
+owner/repo:
+  environments:
+    production:
+      ENV_KEY: op://vault/api/key
+  variables:
+    REGION: eu-central-1
+`)
+		store := createState(t)
+		store.Record("owner/repo/variables/REGION", "eu-central-1")
+
+		plan, err := Build(ctx, configuration, "config", secrets, &github.MockGithubClient{}, store, false, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]Kind{
+			"org/organization/ORG_KEY":                   Create,
+			"owner/repo/environments/production/ENV_KEY": Create,
+			"owner/repo/variables/REGION":                Unchanged,
+		}, kinds(plan))
+	})
+
+	t.Run("should delete unmanaged secrets when pruning", func(t *testing.T) {
+		configuration := createConfiguration(t, "defaults:\n  never_prune: [MANUAL_*]\nowner/repo:\n  API_KEY: op://vault/api/key\n")
+		gh := &github.MockGithubClient{Secrets: map[string][]github.Secret{"owner/repo": {
+			{Name: "API_KEY", UpdatedAt: updatedAt},
+			{Name: "MANUAL_TOKEN", UpdatedAt: updatedAt},
+			{Name: "REMOVED", UpdatedAt: updatedAt},
+		}}}
+
+		plan, err := Build(ctx, configuration, "config", secrets, gh, createState(t), true, false)
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]Kind{
+			"owner/repo/actions/API_KEY": Update,
+			"owner/repo/actions/REMOVED": Delete,
+		}, kinds(plan))
+		assert.Equal(t, reasonNotConfigured, plan.Actions[1].Reason)
+	})
+
+	t.Run("should fail if a value cannot be read", func(t *testing.T) {
+		configuration := createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/missing/key\n")
+
+		_, err := Build(ctx, configuration, "config", secrets, &github.MockGithubClient{}, createState(t), false, false)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "owner/repo/actions/API_KEY")
+	})
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	configuration := createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/api/key\n")
+	gh := &github.MockGithubClient{Secrets: map[string][]github.Secret{"owner/repo": {{Name: "API_KEY", UpdatedAt: updatedAt}}}}
+	store := createState(t)
+
+	build := func(t *testing.T, fingerprint string, secrets mapProvider, gh github.GithubClient) *Plan {
+		plan, err := Build(ctx, configuration, fingerprint, secrets, gh, store, false, false)
+		assert.NoError(t, err)
+		return plan
+	}
+	planned := build(t, "config", mapProvider{"op://vault/api/key": "secret"}, gh)
+
+	t.Run("should accept the same plan", func(t *testing.T) {
+		current := build(t, "config", mapProvider{"op://vault/api/key": "secret"}, gh)
+
+		assert.NoError(t, current.Verify(planned))
+	})
+
+	t.Run("should refuse a changed configuration", func(t *testing.T) {
+		current := build(t, "other", mapProvider{"op://vault/api/key": "secret"}, gh)
+
+		assert.ErrorIs(t, current.Verify(planned), ErrConfigurationChanged)
+	})
+
+	t.Run("should refuse a changed value", func(t *testing.T) {
+		current := build(t, "config", mapProvider{"op://vault/api/key": "rotated"}, gh)
+
+		err := current.Verify(planned)
+
+		assert.ErrorIs(t, err, ErrRemoteChanged)
+		assert.ErrorContains(t, err, "value of owner/repo/actions/API_KEY changed")
+	})
+
+	t.Run("should refuse a secret updated in GitHub", func(t *testing.T) {
+		updated := &github.MockGithubClient{Secrets: map[string][]github.Secret{"owner/repo": {{Name: "API_KEY", UpdatedAt: updatedAt.Add(time.Hour)}}}}
+		current := build(t, "config", mapProvider{"op://vault/api/key": "secret"}, updated)
+
+		assert.ErrorContains(t, current.Verify(planned), "owner/repo/actions/API_KEY was updated in GitHub")
+	})
+
+	t.Run("should refuse a secret deleted in GitHub", func(t *testing.T) {
+		current := build(t, "config", mapProvider{"op://vault/api/key": "secret"}, &github.MockGithubClient{})
+
+		assert.ErrorContains(t, current.Verify(planned), "was planned to update, but now is to create")
+	})
+
+	t.Run("should refuse actions that were not planned", func(t *testing.T) {
+		current := build(t, "config", mapProvider{"op://vault/api/key": "secret"}, gh)
+		current.Actions = append(current.Actions, Action{Kind: Delete, Scope: ScopeRepository, Repository: "owner/repo", App: config.AppActions, Name: "REMOVED"})
+
+		assert.ErrorContains(t, current.Verify(planned), "owner/repo/actions/REMOVED was not planned")
+	})
+
+	t.Run("should refuse planned actions that are gone", func(t *testing.T) {
+		current := build(t, "config", mapProvider{"op://vault/api/key": "secret"}, gh)
+		current.Actions = nil
+
+		assert.ErrorContains(t, current.Verify(planned), "owner/repo/actions/API_KEY is not to update any more")
+	})
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	secrets := mapProvider{"op://vault/api/key": "secret"}
+	configuration := createConfiguration(t, `
+owner/repo:
+  API_KEY: op://vault/api/key
+  DEPENDABOT_KEY:
+    ref: op://vault/api/key
+    apps: [dependabot]
+  environments:
+    production:
+      ENV_KEY: op://vault/api/key
+  variables:
+    REGION: eu-central-1
+`)
+
+	t.Run("should execute the actions and record them in the state", func(t *testing.T) {
+		gh := &github.MockGithubClient{Secrets: map[string][]github.Secret{"owner/repo": {{Name: "REMOVED", UpdatedAt: updatedAt}}}}
+		store := createState(t)
+		plan, err := Build(ctx, configuration, "config", secrets, gh, store, true, false)
+		assert.NoError(t, err)
+
+		ok := plan.Apply(ctx, gh, store)
+
+		assert.True(t, ok)
+		assert.ElementsMatch(t, []string{
+			"owner/repo API_KEY=secret",
+			"owner/repo dependabot DEPENDABOT_KEY=secret",
+			"owner/repo production ENV_KEY=secret",
+			"owner/repo variable REGION=eu-central-1",
+			"delete owner/repo REMOVED",
+		}, gh.Writes)
+		assert.True(t, store.Unchanged("owner/repo/variables/REGION", "eu-central-1"))
+	})
+
+	t.Run("should skip unchanged values", func(t *testing.T) {
+		gh := &github.MockGithubClient{}
+		store := createState(t)
+		store.Record("owner/repo/variables/REGION", "eu-central-1")
+		plan, err := Build(ctx, configuration, "config", secrets, gh, store, false, false)
+		assert.NoError(t, err)
+
+		_ = plan.Apply(ctx, gh, store)
+
+		assert.NotContains(t, gh.Writes, "owner/repo variable REGION=eu-central-1")
+		assert.Len(t, gh.Writes, 3)
+	})
+
+	t.Run("should not record failed actions", func(t *testing.T) {
+		gh := &github.MockGithubClient{Error: assert.AnError}
+		store := createState(t)
+		plan, err := Build(ctx, configuration, "config", secrets, gh, store, false, false)
+		assert.NoError(t, err)
+
+		ok := plan.Apply(ctx, gh, store)
+
+		assert.False(t, ok)
+		assert.Empty(t, store.Fingerprints)
+	})
+
+	t.Run("should not start actions once cancelled", func(t *testing.T) {
+		gh := &github.MockGithubClient{}
+		store := createState(t)
+		plan, err := Build(ctx, configuration, "config", secrets, gh, store, false, false)
+		assert.NoError(t, err)
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		ok := plan.Apply(cancelled, gh, store)
+
+		assert.False(t, ok)
+		assert.Empty(t, gh.Writes)
+	})
+}
+
+func TestPlanFile(t *testing.T) {
+	ctx := context.Background()
+	configuration := createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/api/key\n")
+
+	t.Run("should write the plan without values", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "plan.json")
+		plan, err := Build(ctx, configuration, "config", mapProvider{"op://vault/api/key": "very-secret"}, &github.MockGithubClient{}, createState(t), false, false)
+		assert.NoError(t, err)
+
+		assert.NoError(t, plan.Save(path))
+		content, _ := os.ReadFile(path)
+		read, err := Read(path)
+
+		assert.NoError(t, err)
+		assert.NotContains(t, string(content), "very-secret")
+		assert.NoError(t, plan.Verify(read))
+	})
+
+	t.Run("should print every action with its reason", func(t *testing.T) {
+		var out bytes.Buffer
+		plan, err := Build(ctx, configuration, "config", mapProvider{"op://vault/api/key": "secret"}, &github.MockGithubClient{}, createState(t), false, false)
+		assert.NoError(t, err)
+
+		assert.NoError(t, plan.WriteText(&out))
+
+		assert.Equal(t, "  create     owner/repo/actions/API_KEY (not in GitHub)\nPlan: 1 to create, 0 to update, 0 to delete, 0 unchanged\n", out.String())
+	})
+
+	t.Run("should return an error for plans of another version", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "plan.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"version": 2}`), 0o600))
+
+		_, err := Read(path)
+
+		assert.ErrorContains(t, err, "unsupported version 2")
+	})
+
+	t.Run("should return an error for malformed plans", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "plan.json")
+		assert.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+		_, err := Read(path)
+
+		assert.ErrorContains(t, err, "is malformed")
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Run("should change with the configuration", func(t *testing.T) {
+		first, err := Fingerprint(createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/api/key\n"))
+		assert.NoError(t, err)
+		same, _ := Fingerprint(createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/api/key\n"))
+		other, _ := Fingerprint(createConfiguration(t, "owner/repo:\n  API_KEY: op://vault/other/key\n"))
+
+		assert.Equal(t, first, same)
+		assert.NotEqual(t, first, other)
+	})
+}
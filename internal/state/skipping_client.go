@@ -0,0 +1,95 @@
+package state
+
+import (
+	"context"
+	"log"
+
+	"koenighotze.de/github-distribute-secrets/internal/config"
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+// SkippingClient wraps a GitHub client and skips writing values that are
+// unchanged since the last run, so their updated_at keeps telling when they
+// really changed. Values are written anyway when Force is set.
+type SkippingClient struct {
+	github.GithubClient
+	State *State
+	Force bool
+
+	written int
+	skipped int
+}
+
+// NewSkippingClient wraps the client with the state.
+func NewSkippingClient(client github.GithubClient, state *State, force bool) *SkippingClient {
+	return &SkippingClient{GithubClient: client, State: state, Force: force}
+}
+
+// Written returns the number of values written.
+func (c *SkippingClient) Written() int {
+	return c.written
+}
+
+// Skipped returns the number of unchanged values that were not written.
+func (c *SkippingClient) Skipped() int {
+	return c.skipped
+}
+
+// write calls add unless the value of the target is unchanged, and records
+// the value once add succeeded.
+func (c *SkippingClient) write(target string, value string, add func() error) error {
+	if !c.Force && c.State.Unchanged(target, value) {
+		log.Printf("Skipping %s, it is unchanged", target)
+		c.skipped++
+		return nil
+	}
+
+	if err := add(); err != nil {
+		return err
+	}
+	c.State.Record(target, value)
+	c.written++
+	return nil
+}
+
+func (c *SkippingClient) AddSecretToRepository(ctx context.Context, key string, secret string, repository string) error {
+	return c.write(SecretTarget(repository, config.AppActions, key), secret, func() error {
+		return c.GithubClient.AddSecretToRepository(ctx, key, secret, repository)
+	})
+}
+
+func (c *SkippingClient) AddSecretToApp(ctx context.Context, key string, secret string, app string, repository string) error {
+	return c.write(SecretTarget(repository, app, key), secret, func() error {
+		return c.GithubClient.AddSecretToApp(ctx, key, secret, app, repository)
+	})
+}
+
+func (c *SkippingClient) AddSecretToEnvironment(ctx context.Context, key string, secret string, environment string, repository string) error {
+	return c.write(EnvironmentTarget(repository, environment, key), secret, func() error {
+		return c.GithubClient.AddSecretToEnvironment(ctx, key, secret, environment, repository)
+	})
+}
+
+// AddSecretToOrganization also writes the secret if only its visibility or
+// repositories changed, as they are part of the fingerprint.
+func (c *SkippingClient) AddSecretToOrganization(ctx context.Context, key string, secret string, organization string, visibility string, repositories []string) error {
+	return c.write(OrganizationTarget(organization, key), OrganizationValue(secret, visibility, repositories), func() error {
+		return c.GithubClient.AddSecretToOrganization(ctx, key, secret, organization, visibility, repositories)
+	})
+}
+
+func (c *SkippingClient) AddVariableToRepository(ctx context.Context, name string, value string, repository string) error {
+	return c.write(VariableTarget(repository, name), value, func() error {
+		return c.GithubClient.AddVariableToRepository(ctx, name, value, repository)
+	})
+}
+
+// DeleteSecret forgets the deleted secret, so it is written again should it
+// be configured later on.
+func (c *SkippingClient) DeleteSecret(ctx context.Context, key string, repository string) error {
+	if err := c.GithubClient.DeleteSecret(ctx, key, repository); err != nil {
+		return err
+	}
+	c.State.Forget(SecretTarget(repository, config.AppActions, key))
+	return nil
+}
@@ -0,0 +1,86 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"koenighotze.de/github-distribute-secrets/pkg/github"
+)
+
+func createSkippingClient(t *testing.T, force bool) (*SkippingClient, *github.MockGithubClient) {
+	state, err := Load(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+	client := &github.MockGithubClient{}
+	return NewSkippingClient(client, state, force), client
+}
+
+func TestSkippingClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should skip unchanged secrets", func(t *testing.T) {
+		skipping, client := createSkippingClient(t, false)
+
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "changed", "owner/repo")
+
+		assert.Equal(t, []string{"owner/repo KEY=value", "owner/repo KEY=changed"}, client.Writes)
+		assert.Equal(t, 2, skipping.Written())
+		assert.Equal(t, 1, skipping.Skipped())
+	})
+
+	t.Run("should write unchanged secrets when forced", func(t *testing.T) {
+		skipping, client := createSkippingClient(t, true)
+
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+
+		assert.Len(t, client.Writes, 2)
+		assert.Equal(t, 0, skipping.Skipped())
+	})
+
+	t.Run("should keep the targets of apps, environments and variables apart", func(t *testing.T) {
+		skipping, client := createSkippingClient(t, false)
+
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+		_ = skipping.AddSecretToApp(ctx, "KEY", "value", "dependabot", "owner/repo")
+		_ = skipping.AddSecretToEnvironment(ctx, "KEY", "value", "production", "owner/repo")
+		_ = skipping.AddVariableToRepository(ctx, "KEY", "value", "owner/repo")
+
+		assert.Len(t, client.Writes, 4)
+	})
+
+	t.Run("should write organization secrets whose repositories changed", func(t *testing.T) {
+		skipping, client := createSkippingClient(t, false)
+
+		_ = skipping.AddSecretToOrganization(ctx, "KEY", "value", "org", "selected", []string{"org/a"})
+		_ = skipping.AddSecretToOrganization(ctx, "KEY", "value", "org", "selected", []string{"org/a"})
+		_ = skipping.AddSecretToOrganization(ctx, "KEY", "value", "org", "selected", []string{"org/a", "org/b"})
+
+		assert.Len(t, client.Writes, 2)
+	})
+
+	t.Run("should not record failed writes", func(t *testing.T) {
+		skipping, client := createSkippingClient(t, false)
+		client.Error = assert.AnError
+
+		err := skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.False(t, skipping.State.Unchanged("owner/repo/actions/KEY", "value"))
+		assert.Equal(t, 0, skipping.Written())
+	})
+
+	t.Run("should write deleted secrets again", func(t *testing.T) {
+		skipping, client := createSkippingClient(t, false)
+
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+		_ = skipping.DeleteSecret(ctx, "KEY", "owner/repo")
+		_ = skipping.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+
+		assert.Equal(t, []string{"owner/repo KEY=value", "delete owner/repo KEY", "owner/repo KEY=value"}, client.Writes)
+	})
+}
@@ -0,0 +1,152 @@
+package state
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	stateVersion = 1
+	saltLength   = 32
+)
+
+// State remembers a fingerprint of the last value written to every target,
+// e.g. a secret of a repository. The fingerprint is an HMAC of the value keyed
+// with a random salt, so the state file never contains a value and cannot be
+// checked against guessed values without the salt.
+type State struct {
+	Version      int               `json:"version"`
+	Salt         []byte            `json:"salt"`
+	Fingerprints map[string]string `json:"fingerprints"`
+	path         string
+}
+
+// Load reads the state file at path. A missing file yields an empty state
+// with a new salt, which is written on the first Save.
+func Load(path string) (*State, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newState(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	state := &State{path: path}
+	if err = json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("state file %s is malformed: %w", path, err)
+	}
+	if state.Version != stateVersion {
+		return nil, fmt.Errorf("state file %s has unsupported version %d", path, state.Version)
+	}
+	if len(state.Salt) != saltLength {
+		return nil, fmt.Errorf("state file %s has no valid salt", path)
+	}
+	if state.Fingerprints == nil {
+		state.Fingerprints = make(map[string]string)
+	}
+
+	return state, nil
+}
+
+func newState(path string) (*State, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to create the salt of the state: %w", err)
+	}
+
+	return &State{
+		Version:      stateVersion,
+		Salt:         salt,
+		Fingerprints: make(map[string]string),
+		path:         path,
+	}, nil
+}
+
+// Save writes the state back to its file. The file is replaced at once, so
+// an interrupted run never leaves a truncated state behind.
+func (s *State) Save() error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	defer func() { _ = os.Remove(file.Name()) }()
+
+	if _, err = file.Write(content); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+	if err = os.Rename(file.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", s.path, err)
+	}
+
+	return nil
+}
+
+// SecretTarget names a secret of an app of a repository, e.g. actions.
+func SecretTarget(repository string, app string, key string) string {
+	return repository + "/" + app + "/" + key
+}
+
+// EnvironmentTarget names a secret of an environment of a repository.
+func EnvironmentTarget(repository string, environment string, key string) string {
+	return repository + "/environments/" + environment + "/" + key
+}
+
+// OrganizationTarget names a secret of an organization.
+func OrganizationTarget(organization string, key string) string {
+	return organization + "/organization/" + key
+}
+
+// VariableTarget names a variable of a repository.
+func VariableTarget(repository string, name string) string {
+	return repository + "/variables/" + name
+}
+
+// OrganizationValue combines an organization secret with its visibility and
+// repositories, so changing only them also changes the fingerprint.
+func OrganizationValue(secret string, visibility string, repositories []string) string {
+	return strings.Join(append([]string{secret, visibility}, repositories...), "\x00")
+}
+
+// Fingerprint returns the HMAC of the value written to the target.
+func (s *State) Fingerprint(target string, value string) string {
+	mac := hmac.New(sha256.New, s.Salt)
+	mac.Write([]byte(target))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Unchanged reports whether the value is the one last written to the target.
+func (s *State) Unchanged(target string, value string) bool {
+	fingerprint, ok := s.Fingerprints[target]
+	return ok && hmac.Equal([]byte(fingerprint), []byte(s.Fingerprint(target, value)))
+}
+
+// Record remembers the value as written to the target.
+func (s *State) Record(target string, value string) {
+	s.Fingerprints[target] = s.Fingerprint(target, value)
+}
+
+// Forget drops the fingerprint of a target that no longer exists.
+func (s *State) Forget(target string) {
+	delete(s.Fingerprints, target)
+}
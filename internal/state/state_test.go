@@ -0,0 +1,106 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("should start with an empty state if there is no state file", func(t *testing.T) {
+		state, err := Load(filepath.Join(t.TempDir(), "state.json"))
+
+		assert.NoError(t, err)
+		assert.Empty(t, state.Fingerprints)
+		assert.Len(t, state.Salt, saltLength)
+	})
+
+	t.Run("should read the fingerprints written before", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		state, _ := Load(path)
+		state.Record("owner/repo/actions/KEY", "secret")
+		assert.NoError(t, state.Save())
+
+		result, err := Load(path)
+
+		assert.NoError(t, err)
+		assert.True(t, result.Unchanged("owner/repo/actions/KEY", "secret"))
+	})
+
+	t.Run("should return an error for malformed state files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		assert.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+
+		_, err := Load(path)
+
+		assert.ErrorContains(t, err, "is malformed")
+	})
+
+	t.Run("should return an error for unsupported versions", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"version":2}`), 0o600))
+
+		_, err := Load(path)
+
+		assert.ErrorContains(t, err, "has unsupported version 2")
+	})
+}
+
+func TestSave(t *testing.T) {
+	t.Run("should never write the value", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		state, _ := Load(path)
+		state.Record("owner/repo/actions/KEY", "very-secret-value")
+
+		assert.NoError(t, state.Save())
+
+		content, _ := os.ReadFile(path)
+		assert.False(t, strings.Contains(string(content), "very-secret-value"))
+	})
+
+	t.Run("should only let the owner read the state file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "state.json")
+		state, _ := Load(path)
+
+		assert.NoError(t, state.Save())
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("should return an error if the directory does not exist", func(t *testing.T) {
+		state, _ := Load(filepath.Join(t.TempDir(), "missing", "state.json"))
+
+		assert.ErrorContains(t, state.Save(), "failed to write state file")
+	})
+}
+
+func TestFingerprint(t *testing.T) {
+	state, _ := Load(filepath.Join(t.TempDir(), "state.json"))
+
+	t.Run("should detect changed values", func(t *testing.T) {
+		state.Record("owner/repo/actions/KEY", "old")
+
+		assert.True(t, state.Unchanged("owner/repo/actions/KEY", "old"))
+		assert.False(t, state.Unchanged("owner/repo/actions/KEY", "new"))
+		assert.False(t, state.Unchanged("owner/other/actions/KEY", "old"))
+	})
+
+	t.Run("should depend on the salt", func(t *testing.T) {
+		other, _ := Load(filepath.Join(t.TempDir(), "state.json"))
+
+		assert.NotEqual(t, state.Fingerprint("owner/repo/actions/KEY", "value"), other.Fingerprint("owner/repo/actions/KEY", "value"))
+	})
+
+	t.Run("should forget deleted targets", func(t *testing.T) {
+		state.Record("owner/repo/actions/KEY", "value")
+
+		state.Forget("owner/repo/actions/KEY")
+
+		assert.False(t, state.Unchanged("owner/repo/actions/KEY", "value"))
+	})
+}
@@ -1,25 +1,131 @@
 package cli
 
-import "os/exec"
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
 
+const waitDelay = 5 * time.Second
+
+// Result holds the output of a command. Only Stdout carries the output of
+// the command, Stderr is meant for error messages.
+type Result struct {
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+}
+
+// ExitError reports a command that exited with a non-zero exit code,
+// including what it printed to stderr.
+type ExitError struct {
+	Name     string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("%s exited with code %d", e.Name, e.ExitCode)
+	}
+	return fmt.Sprintf("%s exited with code %d: %s", e.Name, e.ExitCode, e.Stderr)
+}
+
+// CommandRunner runs external commands. A command is killed together with
+// the processes it started when the context is cancelled.
 type CommandRunner interface {
-	Run(name string, args ...string) ([]byte, error)
+	Run(ctx context.Context, name string, args ...string) (Result, error)
+	// RunWithInput runs the command with the input as its standard input, so
+	// secret values never show up in the arguments of a process.
+	RunWithInput(ctx context.Context, input []byte, name string, args ...string) (Result, error)
 }
 
 type cliCommandRunner struct {
-	exec func(name string, arg ...string) ([]byte, error)
+	exec func(ctx context.Context, input []byte, name string, arg ...string) (Result, error)
+	// timeout limits the time a single command may take, zero means no limit.
+	timeout time.Duration
+}
+
+func (c cliCommandRunner) Run(ctx context.Context, name string, args ...string) (Result, error) {
+	return c.run(ctx, nil, name, args...)
+}
+
+func (c cliCommandRunner) RunWithInput(ctx context.Context, input []byte, name string, args ...string) (Result, error) {
+	return c.run(ctx, input, name, args...)
+}
+
+func (c cliCommandRunner) run(ctx context.Context, input []byte, name string, args ...string) (Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	result, err := c.exec(ctx, input, name, args...)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return result, fmt.Errorf("%s timed out after %s: %w", name, c.timeout, ctx.Err())
+	}
+	if err != nil && ctx.Err() != nil {
+		return result, fmt.Errorf("%s was cancelled: %w", name, ctx.Err())
+	}
+	return result, err
+}
+
+func defaultExec(ctx context.Context, input []byte, name string, args ...string) (Result, error) {
+	return execCommand(ctx, true, input, name, args...)
 }
 
-func (c cliCommandRunner) Run(name string, args ...string) ([]byte, error) {
-	return c.exec(name, args...)
+// interactiveExec keeps the command in the process group of the caller, so it
+// may prompt on the terminal, e.g. when op asks to be authorized. Only the
+// command itself is killed on cancellation.
+func interactiveExec(ctx context.Context, input []byte, name string, args ...string) (Result, error) {
+	return execCommand(ctx, false, input, name, args...)
 }
 
-func defaultExec(name string, args ...string) ([]byte, error) {
-	return exec.Command(name, args...).CombinedOutput()
+func execCommand(ctx context.Context, isolate bool, input []byte, name string, args ...string) (Result, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if isolate {
+		killProcessGroupOnCancel(cmd)
+	}
+	// Do not wait forever for children that keep the output open.
+	cmd.WaitDelay = waitDelay
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	result := Result{Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), ExitCode: cmd.ProcessState.ExitCode()}
+
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		err = &ExitError{Name: name, ExitCode: result.ExitCode, Stderr: strings.TrimSpace(stderr.String())}
+	}
+
+	return result, err
+}
+
+// NewCommandRunner returns a runner giving up on commands after the timeout.
+func NewCommandRunner(timeout time.Duration) CommandRunner {
+	return cliCommandRunner{
+		exec:    defaultExec,
+		timeout: timeout,
+	}
 }
 
-func NewCommandRunner() CommandRunner {
+// NewInteractiveCommandRunner returns a runner for commands that may prompt on
+// the terminal. A command in a process group of its own is stopped as soon as
+// it reads from the terminal, so these commands stay in the process group of
+// the caller.
+func NewInteractiveCommandRunner(timeout time.Duration) CommandRunner {
 	return cliCommandRunner{
-		exec: defaultExec,
+		exec:    interactiveExec,
+		timeout: timeout,
 	}
 }
@@ -9,16 +9,34 @@ import (
 )
 
 func TestDefaultExecIntegration(t *testing.T) {
-	t.Run("should return the combined output", func(t *testing.T) {
-		output, error := defaultExec("echo", "foo")
+	t.Run("should return stdout and stderr separately", func(t *testing.T) {
+		result, err := defaultExec(nil, "sh", "-c", "echo foo; echo warning >&2")
 
-		assert.NoError(t, error)
-		assert.Equal(t, "foo\n", string(output))
+		assert.NoError(t, err)
+		assert.Equal(t, "foo\n", string(result.Stdout))
+		assert.Equal(t, "warning\n", string(result.Stderr))
+		assert.Equal(t, 0, result.ExitCode)
+	})
+
+	t.Run("should return the exit code and stderr of failing commands", func(t *testing.T) {
+		result, err := defaultExec(nil, "sh", "-c", "echo broken >&2; exit 3")
+
+		var exitError *ExitError
+		assert.ErrorAs(t, err, &exitError)
+		assert.EqualError(t, err, "sh exited with code 3: broken")
+		assert.Equal(t, 3, result.ExitCode)
 	})
 
 	t.Run("should return the error of the command", func(t *testing.T) {
-		_, error := defaultExec("nosuchcommand")
+		_, error := defaultExec(nil, "nosuchcommand")
 
 		assert.ErrorContains(t, error, "not found")
 	})
+
+	t.Run("should pass the input to the command", func(t *testing.T) {
+		result, err := defaultExec([]byte("secret"), "cat")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", string(result.Stdout))
+	})
 }
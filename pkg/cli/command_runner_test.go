@@ -1,55 +1,126 @@
 package cli
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func TestNewCommandRunner(t *testing.T) {
 	t.Run("should return a valid command runner", func(t *testing.T) {
-		runner := NewCommandRunner()
+		runner := NewCommandRunner(time.Minute)
 
 		assert.NotNil(t, runner)
 		_, ok := runner.(cliCommandRunner)
 		assert.True(t, ok, "Expected NewCommandRunner to return a cliCommandRunner")
 	})
 
+	t.Run("should return a valid interactive command runner", func(t *testing.T) {
+		runner := NewInteractiveCommandRunner(time.Minute)
+
+		assert.NotNil(t, runner)
+		_, ok := runner.(cliCommandRunner)
+		assert.True(t, ok, "Expected NewInteractiveCommandRunner to return a cliCommandRunner")
+	})
+
 	type mockExec struct {
-		called        bool
-		exectedReturn []byte
-		exectedError  error
-		exec          func(name string, arg ...string) ([]byte, error)
+		called         bool
+		receivedInput  []byte
+		expectedReturn Result
+		expectedError  error
+		exec           func(ctx context.Context, input []byte, name string, arg ...string) (Result, error)
 	}
 
 	defaultMockExec := mockExec{}
 	defaultMockExec.called = false
-	defaultMockExec.exec = func(name string, arg ...string) ([]byte, error) {
+	defaultMockExec.exec = func(ctx context.Context, input []byte, name string, arg ...string) (Result, error) {
 		defaultMockExec.called = true
-		return defaultMockExec.exectedReturn, defaultMockExec.exectedError
+		defaultMockExec.receivedInput = input
+		return defaultMockExec.expectedReturn, defaultMockExec.expectedError
 	}
 
 	t.Run("should return the output of the executor", func(t *testing.T) {
-		defaultMockExec.exectedReturn = []byte("thereturn")
+		defaultMockExec.expectedReturn = Result{Stdout: []byte("thereturn")}
 		runner := cliCommandRunner{
 			exec: defaultMockExec.exec,
 		}
 
-		result, err := runner.Run("foo", "bar")
+		result, err := runner.Run(context.Background(), "foo", "bar")
 
-		assert.Equal(t, defaultMockExec.exectedReturn, result)
+		assert.Equal(t, defaultMockExec.expectedReturn, result)
 		assert.Nil(t, err)
+		assert.Nil(t, defaultMockExec.receivedInput)
 	})
 	t.Run("should return the error of the executor", func(t *testing.T) {
-		defaultMockExec.exectedReturn = nil
-		defaultMockExec.exectedError = assert.AnError
+		defaultMockExec.expectedReturn = Result{Stderr: []byte("failed"), ExitCode: 1}
+		defaultMockExec.expectedError = assert.AnError
 		runner := cliCommandRunner{
 			exec: defaultMockExec.exec,
 		}
 
-		result, err := runner.Run("foo", "bar")
+		result, err := runner.Run(context.Background(), "foo", "bar")
 
-		assert.Nil(t, result)
+		assert.Equal(t, 1, result.ExitCode)
 		assert.Equal(t, assert.AnError, err)
 	})
+
+	t.Run("should pass the input to the executor", func(t *testing.T) {
+		defaultMockExec.expectedReturn = Result{Stdout: []byte("thereturn")}
+		defaultMockExec.expectedError = nil
+		runner := cliCommandRunner{
+			exec: defaultMockExec.exec,
+		}
+
+		result, err := runner.RunWithInput(context.Background(), []byte("secret"), "foo", "bar")
+
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("thereturn"), result.Stdout)
+		assert.Equal(t, []byte("secret"), defaultMockExec.receivedInput)
+	})
+
+	blockingExec := func(ctx context.Context, input []byte, name string, arg ...string) (Result, error) {
+		<-ctx.Done()
+		return Result{ExitCode: -1}, assert.AnError
+	}
+
+	t.Run("should give up on commands taking longer than the timeout", func(t *testing.T) {
+		runner := cliCommandRunner{
+			exec:    blockingExec,
+			timeout: time.Millisecond,
+		}
+
+		_, err := runner.Run(context.Background(), "op", "read")
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "op timed out after 1ms")
+	})
+
+	t.Run("should stop commands when the context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		runner := cliCommandRunner{
+			exec: blockingExec,
+		}
+
+		_, err := runner.Run(ctx, "gh", "secret", "set")
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "gh was cancelled")
+	})
+}
+
+func TestExitError(t *testing.T) {
+	t.Run("should include stderr in the message", func(t *testing.T) {
+		err := &ExitError{Name: "op", ExitCode: 1, Stderr: "[ERROR] item not found"}
+
+		assert.EqualError(t, err, "op exited with code 1: [ERROR] item not found")
+	})
+
+	t.Run("should only name the exit code without stderr", func(t *testing.T) {
+		err := &ExitError{Name: "op", ExitCode: 2}
+
+		assert.EqualError(t, err, "op exited with code 2")
+	})
 }
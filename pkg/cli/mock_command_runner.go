@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,15 +13,37 @@ type MockCommandRunner struct {
 }
 
 type ExpectedCommand struct {
-	Name   string
-	Args   []string
-	Output []byte
-	Error  error
+	Name string
+	Args []string
+	// Input is the expected standard input, nil if the command is expected
+	// to be run without input.
+	Input    []byte
+	Stdout   []byte
+	Stderr   []byte
+	ExitCode int
+	Error    error
 }
 
-func (m *MockCommandRunner) Run(name string, args ...string) ([]byte, error) {
+func (m *MockCommandRunner) result() Result {
+	return Result{
+		Stdout:   m.ExpectedCommand.Stdout,
+		Stderr:   m.ExpectedCommand.Stderr,
+		ExitCode: m.ExpectedCommand.ExitCode,
+	}
+}
+
+func (m *MockCommandRunner) Run(ctx context.Context, name string, args ...string) (Result, error) {
+	assert.Equal(m.T, m.ExpectedCommand.Name, name)
+	assert.Equal(m.T, m.ExpectedCommand.Args, args)
+	assert.Nil(m.T, m.ExpectedCommand.Input, "Expected the command to be run with input")
+
+	return m.result(), m.ExpectedCommand.Error
+}
+
+func (m *MockCommandRunner) RunWithInput(ctx context.Context, input []byte, name string, args ...string) (Result, error) {
 	assert.Equal(m.T, m.ExpectedCommand.Name, name)
 	assert.Equal(m.T, m.ExpectedCommand.Args, args)
+	assert.Equal(m.T, m.ExpectedCommand.Input, input)
 
-	return m.ExpectedCommand.Output, m.ExpectedCommand.Error
+	return m.result(), m.ExpectedCommand.Error
 }
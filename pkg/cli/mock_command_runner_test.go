@@ -1,6 +1,7 @@
 package cli
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -13,34 +14,54 @@ func TestMockCommandRunner(t *testing.T) {
 			ExpectedCommand: ExpectedCommand{
 				Name:   "echo",
 				Args:   []string{"hello", "world"},
-				Output: expectedOutput,
+				Stdout: expectedOutput,
 				Error:  nil,
 			},
 			T: t,
 		}
 
-		output, err := mockRunner.Run("echo", "hello", "world")
+		result, err := mockRunner.Run(context.Background(), "echo", "hello", "world")
 
 		assert.NoError(t, err)
-		assert.Equal(t, expectedOutput, output)
+		assert.Equal(t, expectedOutput, result.Stdout)
 	})
 
 	t.Run("should return expected error for commands configured to fail", func(t *testing.T) {
 		expectedError := assert.AnError
 		mockRunner := &MockCommandRunner{
 			ExpectedCommand: ExpectedCommand{
-				Name:   "failing-command",
-				Args:   nil,
-				Output: nil,
-				Error:  expectedError,
+				Name:     "failing-command",
+				Args:     nil,
+				Stderr:   []byte("it failed"),
+				ExitCode: 1,
+				Error:    expectedError,
 			},
 			T: t,
 		}
 
-		output, err := mockRunner.Run("failing-command")
+		result, err := mockRunner.Run(context.Background(), "failing-command")
 
 		assert.Error(t, err)
 		assert.Equal(t, expectedError, err)
-		assert.Empty(t, output)
+		assert.Empty(t, result.Stdout)
+		assert.Equal(t, []byte("it failed"), result.Stderr)
+		assert.Equal(t, 1, result.ExitCode)
+	})
+
+	t.Run("should return expected output for commands run with the expected input", func(t *testing.T) {
+		mockRunner := &MockCommandRunner{
+			ExpectedCommand: ExpectedCommand{
+				Name:   "gh",
+				Args:   []string{"secret", "set", "KEY"},
+				Input:  []byte("secret"),
+				Stdout: []byte("done"),
+			},
+			T: t,
+		}
+
+		result, err := mockRunner.RunWithInput(context.Background(), []byte("secret"), "gh", "secret", "set", "KEY")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("done"), result.Stdout)
 	})
 }
@@ -0,0 +1,51 @@
+//go:build unix
+
+package cli
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKillProcessGroupOnCancel(t *testing.T) {
+	t.Run("should kill the children of a cancelled command", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		started := time.Now()
+
+		// The child inherits stdout, so the command only returns early when
+		// the whole process group is killed.
+		_, err := defaultExec(ctx, nil, "sh", "-c", "sleep 30 & wait")
+
+		assert.Error(t, err)
+		assert.Less(t, time.Since(started), waitDelay)
+	})
+}
+
+func TestProcessGroup(t *testing.T) {
+	processGroupOf := func(t *testing.T, result Result) int {
+		pgid, err := strconv.Atoi(strings.TrimSpace(string(result.Stdout)))
+		assert.NoError(t, err)
+		return pgid
+	}
+
+	t.Run("should start commands in a process group of their own", func(t *testing.T) {
+		result, err := defaultExec(context.Background(), nil, "sh", "-c", "ps -o pgid= -p $$")
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, syscall.Getpgrp(), processGroupOf(t, result))
+	})
+
+	t.Run("should keep interactive commands in the process group of the caller", func(t *testing.T) {
+		result, err := interactiveExec(context.Background(), nil, "sh", "-c", "ps -o pgid= -p $$")
+
+		assert.NoError(t, err)
+		assert.Equal(t, syscall.Getpgrp(), processGroupOf(t, result))
+	})
+}
@@ -1,34 +1,131 @@
 package github
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"koenighotze.de/github-distribute-secrets/pkg/cli"
 )
 
 type GithubClient interface {
-	AddSecretToRepository(key string, secret string, repository string) (err error)
+	AddSecretToRepository(ctx context.Context, key string, secret string, repository string) (err error)
+	AddSecretToApp(ctx context.Context, key string, secret string, app string, repository string) (err error)
+	AddSecretToEnvironment(ctx context.Context, key string, secret string, environment string, repository string) (err error)
+	AddSecretToOrganization(ctx context.Context, key string, secret string, organization string, visibility string, repositories []string) (err error)
+	AddVariableToRepository(ctx context.Context, name string, value string, repository string) (err error)
+	ListRepositories(ctx context.Context, owner string) (repositories []string, err error)
+	ListSecrets(ctx context.Context, repository string) (secrets []Secret, err error)
+	DeleteSecret(ctx context.Context, key string, repository string) (err error)
+}
+
+// Secret is an Actions secret of a repository as listed by GitHub, which
+// never returns the value.
+type Secret struct {
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 type cliGithubClient struct {
 	runner cli.CommandRunner
 }
 
-func (gh *cliGithubClient) AddSecretToRepository(key string, secret string, repository string) (err error) {
+func (gh *cliGithubClient) AddSecretToRepository(ctx context.Context, key string, secret string, repository string) (err error) {
 	log.Printf("In repository %s. Adding secret with key %s", repository, key)
-	if _, err = gh.runner.Run("gh", "secret", "set", key, "--body", secret, "--repo", repository); err != nil {
+	if _, err = gh.runner.RunWithInput(ctx, []byte(secret), "gh", "secret", "set", key, "--repo", repository); err != nil {
 		return fmt.Errorf("failed adding secret as key %s to repository %s: %w", key, repository, err)
 	}
 	return nil
 }
 
-func NewClient(dryRun bool) GithubClient {
+func (gh *cliGithubClient) AddSecretToApp(ctx context.Context, key string, secret string, app string, repository string) (err error) {
+	log.Printf("In repository %s. Adding %s secret with key %s", repository, app, key)
+	if _, err = gh.runner.RunWithInput(ctx, []byte(secret), "gh", "secret", "set", key, "--app", app, "--repo", repository); err != nil {
+		return fmt.Errorf("failed adding %s secret as key %s to repository %s: %w", app, key, repository, err)
+	}
+	return nil
+}
+
+func (gh *cliGithubClient) AddSecretToEnvironment(ctx context.Context, key string, secret string, environment string, repository string) (err error) {
+	log.Printf("In repository %s. Adding secret with key %s to environment %s", repository, key, environment)
+	if _, err = gh.runner.RunWithInput(ctx, []byte(secret), "gh", "secret", "set", key, "--env", environment, "--repo", repository); err != nil {
+		return fmt.Errorf("failed adding secret as key %s to environment %s of repository %s: %w", key, environment, repository, err)
+	}
+	return nil
+}
+
+func (gh *cliGithubClient) AddSecretToOrganization(ctx context.Context, key string, secret string, organization string, visibility string, repositories []string) (err error) {
+	log.Printf("In organization %s. Adding secret with key %s and visibility %s", organization, key, visibility)
+	args := []string{"secret", "set", key, "--org", organization, "--visibility", visibility}
+	if visibility == "selected" {
+		args = append(args, "--repos", strings.Join(repositories, ","))
+	}
+	if _, err = gh.runner.RunWithInput(ctx, []byte(secret), "gh", args...); err != nil {
+		return fmt.Errorf("failed adding secret as key %s to organization %s: %w", key, organization, err)
+	}
+	return nil
+}
+
+func (gh *cliGithubClient) AddVariableToRepository(ctx context.Context, name string, value string, repository string) (err error) {
+	log.Printf("In repository %s. Adding variable %s", repository, name)
+	if _, err = gh.runner.RunWithInput(ctx, []byte(value), "gh", "variable", "set", name, "--repo", repository); err != nil {
+		return fmt.Errorf("failed adding variable %s to repository %s: %w", name, repository, err)
+	}
+	return nil
+}
+
+func (gh *cliGithubClient) DeleteSecret(ctx context.Context, key string, repository string) (err error) {
+	log.Printf("In repository %s. Deleting secret with key %s", repository, key)
+	if _, err = gh.runner.Run(ctx, "gh", "secret", "delete", key, "--repo", repository); err != nil {
+		return fmt.Errorf("failed deleting secret with key %s from repository %s: %w", key, repository, err)
+	}
+	return nil
+}
+
+func (gh *cliGithubClient) ListRepositories(ctx context.Context, owner string) (repositories []string, err error) {
+	return listRepositories(ctx, gh.runner, owner)
+}
+
+func (gh *cliGithubClient) ListSecrets(ctx context.Context, repository string) (secrets []Secret, err error) {
+	return listSecrets(ctx, gh.runner, repository)
+}
+
+// listRepositories returns the full names of the repositories of the owner.
+// It only reads from GitHub, so the dry run client uses it as well.
+func listRepositories(ctx context.Context, runner cli.CommandRunner, owner string) (repositories []string, err error) {
+	result, err := runner.Run(ctx, "gh", "repo", "list", owner, "--limit", "1000", "--json", "nameWithOwner", "--jq", ".[].nameWithOwner")
+	if err != nil {
+		return nil, fmt.Errorf("failed listing repositories of %s: %w", owner, err)
+	}
+
+	return strings.Fields(string(result.Stdout)), nil
+}
+
+// listSecrets returns the Actions secrets of the repository. Like
+// listRepositories it is shared with the dry run client.
+func listSecrets(ctx context.Context, runner cli.CommandRunner, repository string) (secrets []Secret, err error) {
+	result, err := runner.Run(ctx, "gh", "secret", "list", "--repo", repository, "--json", "name,updatedAt")
+	if err != nil {
+		return nil, fmt.Errorf("failed listing secrets of repository %s: %w", repository, err)
+	}
+
+	if err = json.Unmarshal(result.Stdout, &secrets); err != nil {
+		return nil, fmt.Errorf("unexpected secrets of repository %s: %w", repository, err)
+	}
+	return secrets, nil
+}
+
+// NewClient returns a client calling the gh CLI. A single call of gh is
+// stopped after the timeout.
+func NewClient(dryRun bool, timeout time.Duration) GithubClient {
 	if dryRun {
-		return withDryRun()
+		return withDryRun(timeout)
 	}
 
 	return &cliGithubClient{
-		runner: cli.NewCommandRunner(),
+		runner: cli.NewCommandRunner(timeout),
 	}
 }
@@ -1,7 +1,9 @@
 package github
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -12,18 +14,20 @@ const (
 	testSecretKey   = "TEST_KEY"
 	testSecretValue = "test-secret"
 	testRepoName    = "test-repo"
+	testEnvironment = "production"
+	testOrgName     = "test-org"
 )
 
 func TestNewClient(t *testing.T) {
 	t.Run("should return a client if dry run is false", func(t *testing.T) {
-		result := NewClient(false)
+		result := NewClient(false, time.Minute)
 
 		_, ok := result.(*cliGithubClient)
 		assert.True(t, ok, "Expected runner to be of type cli.CommandRunner")
 	})
 
 	t.Run("should return a dry run client if dry run is true", func(t *testing.T) {
-		result := NewClient(true)
+		result := NewClient(true, time.Minute)
 
 		_, ok := result.(*dryRunGithubClient)
 		assert.True(t, ok, "Expected runner to be of type cli.CommandRunner")
@@ -35,8 +39,9 @@ func createMockCommandRunner(t *testing.T, output []byte, err error) cli.Command
 	return &cli.MockCommandRunner{
 		ExpectedCommand: cli.ExpectedCommand{
 			Name:   "gh",
-			Args:   []string{"secret", "set", testSecretKey, "--body", testSecretValue, "--repo", testRepoName},
-			Output: output,
+			Args:   []string{"secret", "set", testSecretKey, "--repo", testRepoName},
+			Input:  []byte(testSecretValue),
+			Stdout: output,
 			Error:  err,
 		},
 		T: t,
@@ -55,7 +60,7 @@ func TestCreateMockCommandRunner(t *testing.T) {
 
 		assert.Equal(t, "gh", mockRunnerConcrete.ExpectedCommand.Name,
 			"Expected command name to be 'gh'")
-		assert.Equal(t, expectedOutput, mockRunnerConcrete.ExpectedCommand.Output,
+		assert.Equal(t, expectedOutput, mockRunnerConcrete.ExpectedCommand.Stdout,
 			"Expected output to match")
 		assert.Equal(t, expectedError, mockRunnerConcrete.ExpectedCommand.Error,
 			"Expected error to match")
@@ -69,7 +74,7 @@ func TestAddSecretToRepository(t *testing.T) {
 			runner: mockRunner,
 		}
 
-		err := client.AddSecretToRepository(testSecretKey, testSecretValue, testRepoName)
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
 
 		assert.NoError(t, err, "Expected no error when adding secret")
 	})
@@ -81,9 +86,289 @@ func TestAddSecretToRepository(t *testing.T) {
 			runner: mockRunner,
 		}
 
-		err := client.AddSecretToRepository(testSecretKey, testSecretValue, testRepoName)
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
 
 		assert.Error(t, err)
 		assert.ErrorIs(t, err, mockError)
 	})
 }
+
+func TestAddSecretToApp(t *testing.T) {
+	createAppMockCommandRunner := func(t *testing.T, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:  "gh",
+				Args:  []string{"secret", "set", testSecretKey, "--app", "dependabot", "--repo", testRepoName},
+				Input: []byte(testSecretValue),
+				Error: err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should add a secret for the app successfully", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createAppMockCommandRunner(t, nil),
+		}
+
+		err := client.AddSecretToApp(context.Background(), testSecretKey, testSecretValue, "dependabot", testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error naming the app if adding the secret fails", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createAppMockCommandRunner(t, assert.AnError),
+		}
+
+		err := client.AddSecretToApp(context.Background(), testSecretKey, testSecretValue, "dependabot", testRepoName)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed adding dependabot secret")
+	})
+}
+
+func TestAddSecretToEnvironment(t *testing.T) {
+	createEnvironmentMockCommandRunner := func(t *testing.T, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:  "gh",
+				Args:  []string{"secret", "set", testSecretKey, "--env", testEnvironment, "--repo", testRepoName},
+				Input: []byte(testSecretValue),
+				Error: err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should add a secret to the environment successfully", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createEnvironmentMockCommandRunner(t, nil),
+		}
+
+		err := client.AddSecretToEnvironment(context.Background(), testSecretKey, testSecretValue, testEnvironment, testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error naming the environment if adding the secret fails", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createEnvironmentMockCommandRunner(t, assert.AnError),
+		}
+
+		err := client.AddSecretToEnvironment(context.Background(), testSecretKey, testSecretValue, testEnvironment, testRepoName)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "environment production")
+	})
+}
+
+func TestAddSecretToOrganization(t *testing.T) {
+	createOrganizationMockCommandRunner := func(t *testing.T, args []string, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:  "gh",
+				Args:  args,
+				Input: []byte(testSecretValue),
+				Error: err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should add a secret visible to all repositories", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createOrganizationMockCommandRunner(t,
+				[]string{"secret", "set", testSecretKey, "--org", testOrgName, "--visibility", "all"}, nil),
+		}
+
+		err := client.AddSecretToOrganization(context.Background(), testSecretKey, testSecretValue, testOrgName, "all", nil)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should pass the repositories if the visibility is selected", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createOrganizationMockCommandRunner(t,
+				[]string{"secret", "set", testSecretKey, "--org", testOrgName, "--visibility", "selected", "--repos", "test-org/a,test-org/b"}, nil),
+		}
+
+		err := client.AddSecretToOrganization(context.Background(), testSecretKey, testSecretValue, testOrgName, "selected", []string{"test-org/a", "test-org/b"})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error naming the organization if adding the secret fails", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createOrganizationMockCommandRunner(t,
+				[]string{"secret", "set", testSecretKey, "--org", testOrgName, "--visibility", "private"}, assert.AnError),
+		}
+
+		err := client.AddSecretToOrganization(context.Background(), testSecretKey, testSecretValue, testOrgName, "private", nil)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "organization test-org")
+	})
+}
+
+func TestDeleteSecret(t *testing.T) {
+	createDeleteMockCommandRunner := func(t *testing.T, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:  "gh",
+				Args:  []string{"secret", "delete", testSecretKey, "--repo", testRepoName},
+				Error: err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should delete the secret of the repository", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createDeleteMockCommandRunner(t, nil),
+		}
+
+		err := client.DeleteSecret(context.Background(), testSecretKey, testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error if deleting the secret fails", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createDeleteMockCommandRunner(t, assert.AnError),
+		}
+
+		err := client.DeleteSecret(context.Background(), testSecretKey, testRepoName)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed deleting secret with key TEST_KEY from repository test-repo")
+	})
+}
+
+func TestAddVariableToRepository(t *testing.T) {
+	createVariableMockCommandRunner := func(t *testing.T, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:  "gh",
+				Args:  []string{"variable", "set", testSecretKey, "--repo", testRepoName},
+				Input: []byte(testSecretValue),
+				Error: err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should add a variable to the repository successfully", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createVariableMockCommandRunner(t, nil),
+		}
+
+		err := client.AddVariableToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error if adding the variable fails", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createVariableMockCommandRunner(t, assert.AnError),
+		}
+
+		err := client.AddVariableToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed adding variable TEST_KEY")
+	})
+}
+
+func TestListRepositories(t *testing.T) {
+	createListMockCommandRunner := func(t *testing.T, output []byte, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:   "gh",
+				Args:   []string{"repo", "list", testOrgName, "--limit", "1000", "--json", "nameWithOwner", "--jq", ".[].nameWithOwner"},
+				Stdout: output,
+				Error:  err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should return the repositories of the owner", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createListMockCommandRunner(t, []byte("test-org/a\ntest-org/b\n"), nil),
+		}
+
+		result, err := client.ListRepositories(context.Background(), testOrgName)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"test-org/a", "test-org/b"}, result)
+	})
+
+	t.Run("should return an error if listing the repositories fails", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createListMockCommandRunner(t, nil, assert.AnError),
+		}
+
+		_, err := client.ListRepositories(context.Background(), testOrgName)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed listing repositories of test-org")
+	})
+
+	t.Run("should list the repositories in dry run mode as well", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createListMockCommandRunner(t, []byte("test-org/a\n"), nil),
+		}
+
+		result, err := client.ListRepositories(context.Background(), testOrgName)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"test-org/a"}, result)
+	})
+}
+
+func TestListSecrets(t *testing.T) {
+	createSecretsMockCommandRunner := func(t *testing.T, output []byte, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:   "gh",
+				Args:   []string{"secret", "list", "--repo", testRepoName, "--json", "name,updatedAt"},
+				Stdout: output,
+				Error:  err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should return the secrets of the repository", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createSecretsMockCommandRunner(t, []byte(`[{"name":"TEST_KEY","updatedAt":"2026-01-02T03:04:05Z"}]`), nil),
+		}
+
+		result, err := client.ListSecrets(context.Background(), testRepoName)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Secret{{Name: testSecretKey, UpdatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}}, result)
+	})
+
+	t.Run("should return an error if listing the secrets fails", func(t *testing.T) {
+		client := cliGithubClient{
+			runner: createSecretsMockCommandRunner(t, nil, assert.AnError),
+		}
+
+		_, err := client.ListSecrets(context.Background(), testRepoName)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed listing secrets of repository test-repo")
+	})
+
+	t.Run("should return an error for unexpected output", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createSecretsMockCommandRunner(t, []byte("TEST_KEY\tUpdated 2026-01-02"), nil),
+		}
+
+		_, err := client.ListSecrets(context.Background(), testRepoName)
+
+		assert.ErrorContains(t, err, "unexpected secrets of repository test-repo")
+	})
+}
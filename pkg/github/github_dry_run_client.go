@@ -1,8 +1,11 @@
 package github
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
 	"koenighotze.de/github-distribute-secrets/pkg/cli"
 )
@@ -11,16 +14,67 @@ type dryRunGithubClient struct {
 	runner cli.CommandRunner
 }
 
-func (gh *dryRunGithubClient) AddSecretToRepository(key string, secret string, repository string) (err error) {
+func (gh *dryRunGithubClient) AddSecretToRepository(ctx context.Context, key string, secret string, repository string) (err error) {
 	log.Printf("DRY RUN: In repository %s. Should add secret with key %s", repository, key)
-	if _, err = gh.runner.Run("gh", "repo", "view", repository); err != nil {
+	if _, err = gh.runner.Run(ctx, "gh", "repo", "view", repository); err != nil {
 		return fmt.Errorf("repository %s does not seem to exist. %w", repository, err)
 	}
 	return nil
 }
 
-func withDryRun() GithubClient {
+func (gh *dryRunGithubClient) AddSecretToApp(ctx context.Context, key string, secret string, app string, repository string) (err error) {
+	log.Printf("DRY RUN: In repository %s. Should add %s secret with key %s", repository, app, key)
+	if _, err = gh.runner.Run(ctx, "gh", "repo", "view", repository); err != nil {
+		return fmt.Errorf("repository %s does not seem to exist. %w", repository, err)
+	}
+	return nil
+}
+
+func (gh *dryRunGithubClient) AddSecretToEnvironment(ctx context.Context, key string, secret string, environment string, repository string) (err error) {
+	log.Printf("DRY RUN: In repository %s. Should add secret with key %s to environment %s", repository, key, environment)
+	if _, err = gh.runner.Run(ctx, "gh", "api", fmt.Sprintf("repos/%s/environments/%s", repository, environment)); err != nil {
+		return fmt.Errorf("environment %s of repository %s does not seem to exist. %w", environment, repository, err)
+	}
+	return nil
+}
+
+func (gh *dryRunGithubClient) AddSecretToOrganization(ctx context.Context, key string, secret string, organization string, visibility string, repositories []string) (err error) {
+	log.Printf("DRY RUN: In organization %s. Should add secret with key %s and visibility %s", organization, key, visibility)
+	if visibility == "selected" {
+		log.Printf("DRY RUN: Secret with key %s should be visible to repositories %s", key, strings.Join(repositories, ", "))
+	}
+	if _, err = gh.runner.Run(ctx, "gh", "api", fmt.Sprintf("orgs/%s", organization)); err != nil {
+		return fmt.Errorf("organization %s does not seem to exist. %w", organization, err)
+	}
+	return nil
+}
+
+func (gh *dryRunGithubClient) AddVariableToRepository(ctx context.Context, name string, value string, repository string) (err error) {
+	log.Printf("DRY RUN: In repository %s. Should add variable %s", repository, name)
+	if _, err = gh.runner.Run(ctx, "gh", "repo", "view", repository); err != nil {
+		return fmt.Errorf("repository %s does not seem to exist. %w", repository, err)
+	}
+	return nil
+}
+
+func (gh *dryRunGithubClient) DeleteSecret(ctx context.Context, key string, repository string) (err error) {
+	log.Printf("DRY RUN: In repository %s. Should delete secret with key %s", repository, key)
+	if _, err = gh.runner.Run(ctx, "gh", "repo", "view", repository); err != nil {
+		return fmt.Errorf("repository %s does not seem to exist. %w", repository, err)
+	}
+	return nil
+}
+
+func (gh *dryRunGithubClient) ListRepositories(ctx context.Context, owner string) (repositories []string, err error) {
+	return listRepositories(ctx, gh.runner, owner)
+}
+
+func (gh *dryRunGithubClient) ListSecrets(ctx context.Context, repository string) (secrets []Secret, err error) {
+	return listSecrets(ctx, gh.runner, repository)
+}
+
+func withDryRun(timeout time.Duration) GithubClient {
 	return &dryRunGithubClient{
-		runner: cli.NewCommandRunner(),
+		runner: cli.NewCommandRunner(timeout),
 	}
 }
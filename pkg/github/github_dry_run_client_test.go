@@ -1,8 +1,10 @@
 package github
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"koenighotze.de/github-distribute-secrets/pkg/cli"
@@ -10,7 +12,7 @@ import (
 
 func TestWithDryRun(t *testing.T) {
 	t.Run("should return the dry run client", func(t *testing.T) {
-		result := withDryRun()
+		result := withDryRun(time.Minute)
 
 		_, ok := result.(*dryRunGithubClient)
 		assert.True(t, ok, "Expected result to be of type *dryRunGithubClient")
@@ -24,7 +26,7 @@ func TestDryRunAddSecretToRepository(t *testing.T) {
 			runner: mockRunner,
 		}
 
-		err := client.AddSecretToRepository(testSecretKey, testSecretValue, testRepoName)
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
 
 		assert.NoError(t, err, "Expected no error in dry run mode for existing repository")
 	})
@@ -36,19 +38,151 @@ func TestDryRunAddSecretToRepository(t *testing.T) {
 			runner: mockRunner,
 		}
 
-		err := client.AddSecretToRepository(testSecretKey, testSecretValue, testRepoName)
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
 
 		assert.Error(t, err, "Expected error when repository doesn't exist")
 		assert.Contains(t, err.Error(), "repository test-repo does not seem to exist")
 	})
 }
 
+func TestDryRunAddSecretToApp(t *testing.T) {
+	t.Run("should return nil if the repository exists", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createDryRunMockCommandRunner(t, nil, nil),
+		}
+
+		err := client.AddSecretToApp(context.Background(), testSecretKey, testSecretValue, "codespaces", testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error if the repository does not exist", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createDryRunMockCommandRunner(t, nil, assert.AnError),
+		}
+
+		err := client.AddSecretToApp(context.Background(), testSecretKey, testSecretValue, "codespaces", testRepoName)
+
+		assert.ErrorContains(t, err, "repository test-repo does not seem to exist")
+	})
+}
+
+func TestDryRunAddSecretToEnvironment(t *testing.T) {
+	createEnvironmentMockCommandRunner := func(t *testing.T, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:  "gh",
+				Args:  []string{"api", "repos/test-repo/environments/production"},
+				Error: err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should return nil if the environment exists", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createEnvironmentMockCommandRunner(t, nil),
+		}
+
+		err := client.AddSecretToEnvironment(context.Background(), testSecretKey, testSecretValue, testEnvironment, testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error if the environment does not exist", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createEnvironmentMockCommandRunner(t, assert.AnError),
+		}
+
+		err := client.AddSecretToEnvironment(context.Background(), testSecretKey, testSecretValue, testEnvironment, testRepoName)
+
+		assert.ErrorContains(t, err, "environment production of repository test-repo does not seem to exist")
+	})
+}
+
+func TestDryRunAddSecretToOrganization(t *testing.T) {
+	createOrganizationMockCommandRunner := func(t *testing.T, err error) cli.CommandRunner {
+		return &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:  "gh",
+				Args:  []string{"api", "orgs/test-org"},
+				Error: err,
+			},
+			T: t,
+		}
+	}
+
+	t.Run("should return nil if the organization exists", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createOrganizationMockCommandRunner(t, nil),
+		}
+
+		err := client.AddSecretToOrganization(context.Background(), testSecretKey, testSecretValue, testOrgName, "selected", []string{"test-org/a"})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error if the organization does not exist", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createOrganizationMockCommandRunner(t, assert.AnError),
+		}
+
+		err := client.AddSecretToOrganization(context.Background(), testSecretKey, testSecretValue, testOrgName, "all", nil)
+
+		assert.ErrorContains(t, err, "organization test-org does not seem to exist")
+	})
+}
+
+func TestDryRunAddVariableToRepository(t *testing.T) {
+	t.Run("should return nil if the repository exists", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createDryRunMockCommandRunner(t, nil, nil),
+		}
+
+		err := client.AddVariableToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error if the repository does not exist", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createDryRunMockCommandRunner(t, nil, assert.AnError),
+		}
+
+		err := client.AddVariableToRepository(context.Background(), testSecretKey, testSecretValue, testRepoName)
+
+		assert.ErrorContains(t, err, "repository test-repo does not seem to exist")
+	})
+}
+
+func TestDryRunDeleteSecret(t *testing.T) {
+	t.Run("should only check that the repository exists", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createDryRunMockCommandRunner(t, nil, nil),
+		}
+
+		err := client.DeleteSecret(context.Background(), testSecretKey, testRepoName)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("should return an error if the repository does not exist", func(t *testing.T) {
+		client := dryRunGithubClient{
+			runner: createDryRunMockCommandRunner(t, nil, assert.AnError),
+		}
+
+		err := client.DeleteSecret(context.Background(), testSecretKey, testRepoName)
+
+		assert.ErrorContains(t, err, "repository test-repo does not seem to exist")
+	})
+}
+
 func createDryRunMockCommandRunner(t *testing.T, output []byte, err error) cli.CommandRunner {
 	return &cli.MockCommandRunner{
 		ExpectedCommand: cli.ExpectedCommand{
 			Name:   "gh",
 			Args:   []string{"repo", "view", testRepoName},
-			Output: output,
+			Stdout: output,
 			Error:  err,
 		},
 		T: t,
@@ -0,0 +1,366 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
+
+	"koenighotze.de/github-distribute-secrets/pkg/cli"
+)
+
+// Environment variables configuring the access to the REST API. GITHUB_API_URL
+// is set by GitHub Actions and points to GitHub Enterprise Server as well.
+const (
+	TokenEnv      = "GH_TOKEN"
+	APIURLEnv     = "GITHUB_API_URL"
+	defaultAPIURL = "https://api.github.com"
+	apiVersion    = "2022-11-28"
+	pageSize      = 100
+)
+
+// publicKey is the key of a repository, environment or organization that
+// secrets are encrypted with before they are uploaded.
+type publicKey struct {
+	KeyID string `json:"key_id"`
+	Key   string `json:"key"`
+}
+
+// errNotFound is returned for requests answered with 404 Not Found.
+var errNotFound = errors.New("not found")
+
+// restGithubClient calls the REST API of GitHub. Secrets are encrypted
+// locally with a sealed box for the public key of their target, so the plain
+// values never leave the process. In dry run mode only the reading requests
+// are sent, which still shows whether the targets exist.
+type restGithubClient struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+	dryRun     bool
+	// publicKeys caches the public key of every secrets endpoint.
+	publicKeys map[string]publicKey
+}
+
+func (gh *restGithubClient) AddSecretToRepository(ctx context.Context, key string, secret string, repository string) (err error) {
+	log.Printf("%sIn repository %s. Adding secret with key %s", gh.logPrefix(), repository, key)
+	if err = gh.putSecret(ctx, "repos/"+repository+"/actions/secrets", key, secret, nil); err != nil {
+		return fmt.Errorf("failed adding secret as key %s to repository %s: %w", key, repository, err)
+	}
+	return nil
+}
+
+func (gh *restGithubClient) AddSecretToApp(ctx context.Context, key string, secret string, app string, repository string) (err error) {
+	log.Printf("%sIn repository %s. Adding %s secret with key %s", gh.logPrefix(), repository, app, key)
+	if err = gh.putSecret(ctx, "repos/"+repository+"/"+app+"/secrets", key, secret, nil); err != nil {
+		return fmt.Errorf("failed adding %s secret as key %s to repository %s: %w", app, key, repository, err)
+	}
+	return nil
+}
+
+func (gh *restGithubClient) AddSecretToEnvironment(ctx context.Context, key string, secret string, environment string, repository string) (err error) {
+	log.Printf("%sIn repository %s. Adding secret with key %s to environment %s", gh.logPrefix(), repository, key, environment)
+	if err = gh.putSecret(ctx, "repos/"+repository+"/environments/"+url.PathEscape(environment)+"/secrets", key, secret, nil); err != nil {
+		return fmt.Errorf("failed adding secret as key %s to environment %s of repository %s: %w", key, environment, repository, err)
+	}
+	return nil
+}
+
+func (gh *restGithubClient) AddSecretToOrganization(ctx context.Context, key string, secret string, organization string, visibility string, repositories []string) (err error) {
+	log.Printf("%sIn organization %s. Adding secret with key %s and visibility %s", gh.logPrefix(), organization, key, visibility)
+	fields := map[string]any{"visibility": visibility}
+	if visibility == "selected" {
+		ids, err := gh.repositoryIDs(ctx, repositories)
+		if err != nil {
+			return fmt.Errorf("failed adding secret as key %s to organization %s: %w", key, organization, err)
+		}
+		fields["selected_repository_ids"] = ids
+	}
+	if err = gh.putSecret(ctx, "orgs/"+organization+"/actions/secrets", key, secret, fields); err != nil {
+		return fmt.Errorf("failed adding secret as key %s to organization %s: %w", key, organization, err)
+	}
+	return nil
+}
+
+// AddVariableToRepository creates the variable, or updates it if it exists.
+func (gh *restGithubClient) AddVariableToRepository(ctx context.Context, name string, value string, repository string) (err error) {
+	log.Printf("%sIn repository %s. Adding variable %s", gh.logPrefix(), repository, name)
+	if gh.dryRun {
+		err = gh.request(ctx, http.MethodGet, "repos/"+repository, nil, nil)
+	} else {
+		body := map[string]string{"name": name, "value": value}
+		err = gh.request(ctx, http.MethodPatch, "repos/"+repository+"/actions/variables/"+url.PathEscape(name), body, nil)
+		if errors.Is(err, errNotFound) {
+			err = gh.request(ctx, http.MethodPost, "repos/"+repository+"/actions/variables", body, nil)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed adding variable %s to repository %s: %w", name, repository, err)
+	}
+	return nil
+}
+
+func (gh *restGithubClient) DeleteSecret(ctx context.Context, key string, repository string) (err error) {
+	log.Printf("%sIn repository %s. Deleting secret with key %s", gh.logPrefix(), repository, key)
+	if gh.dryRun {
+		err = gh.request(ctx, http.MethodGet, "repos/"+repository, nil, nil)
+	} else {
+		err = gh.request(ctx, http.MethodDelete, "repos/"+repository+"/actions/secrets/"+url.PathEscape(key), nil, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed deleting secret with key %s from repository %s: %w", key, repository, err)
+	}
+	return nil
+}
+
+// ListRepositories lists the repositories of an organization, or those of a
+// user if the owner is not an organization.
+func (gh *restGithubClient) ListRepositories(ctx context.Context, owner string) (repositories []string, err error) {
+	repositories, err = gh.listRepositoryPages(ctx, "orgs/"+url.PathEscape(owner)+"/repos?type=all", "")
+	if errors.Is(err, errNotFound) {
+		repositories, err = gh.listUserRepositories(ctx, owner)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed listing repositories of %s: %w", owner, err)
+	}
+	return repositories, nil
+}
+
+func (gh *restGithubClient) ListSecrets(ctx context.Context, repository string) (secrets []Secret, err error) {
+	secrets = make([]Secret, 0)
+	for page := 1; ; page++ {
+		var result struct {
+			TotalCount int `json:"total_count"`
+			Secrets    []struct {
+				Name      string    `json:"name"`
+				UpdatedAt time.Time `json:"updated_at"`
+			} `json:"secrets"`
+		}
+		if err = gh.request(ctx, http.MethodGet, fmt.Sprintf("repos/%s/actions/secrets?per_page=%d&page=%d", repository, pageSize, page), nil, &result); err != nil {
+			return nil, fmt.Errorf("failed listing secrets of repository %s: %w", repository, err)
+		}
+
+		for _, secret := range result.Secrets {
+			secrets = append(secrets, Secret{Name: secret.Name, UpdatedAt: secret.UpdatedAt})
+		}
+		if len(result.Secrets) == 0 || len(secrets) >= result.TotalCount {
+			return secrets, nil
+		}
+	}
+}
+
+// listUserRepositories lists the repositories owned by a user. Only the
+// authenticated user sees its private repositories, for everyone else GitHub
+// lists the public ones.
+func (gh *restGithubClient) listUserRepositories(ctx context.Context, owner string) ([]string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := gh.request(ctx, http.MethodGet, "user", nil, &user); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(user.Login, owner) {
+		return gh.listRepositoryPages(ctx, "user/repos?affiliation=owner", owner)
+	}
+	return gh.listRepositoryPages(ctx, "users/"+url.PathEscape(owner)+"/repos?type=owner", "")
+}
+
+// listRepositoryPages reads every page of a repository listing. If owner is
+// set, only repositories of that owner are returned.
+func (gh *restGithubClient) listRepositoryPages(ctx context.Context, path string, owner string) ([]string, error) {
+	var repositories []string
+	for page := 1; ; page++ {
+		var result []struct {
+			FullName string `json:"full_name"`
+			Owner    struct {
+				Login string `json:"login"`
+			} `json:"owner"`
+		}
+		if err := gh.request(ctx, http.MethodGet, fmt.Sprintf("%s&per_page=%d&page=%d", path, pageSize, page), nil, &result); err != nil {
+			return nil, err
+		}
+
+		for _, repository := range result {
+			if owner == "" || strings.EqualFold(repository.Owner.Login, owner) {
+				repositories = append(repositories, repository.FullName)
+			}
+		}
+		if len(result) < pageSize {
+			return repositories, nil
+		}
+	}
+}
+
+// putSecret encrypts the secret for the public key of the secrets endpoint
+// and uploads it together with the extra fields.
+func (gh *restGithubClient) putSecret(ctx context.Context, endpoint string, key string, secret string, fields map[string]any) error {
+	publicKey, err := gh.publicKey(ctx, endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to read the public key: %w", err)
+	}
+	if gh.dryRun {
+		return nil
+	}
+
+	encrypted, err := encryptSecret(publicKey.Key, secret)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]any{"encrypted_value": encrypted, "key_id": publicKey.KeyID}
+	for name, value := range fields {
+		body[name] = value
+	}
+	return gh.request(ctx, http.MethodPut, endpoint+"/"+url.PathEscape(key), body, nil)
+}
+
+func (gh *restGithubClient) publicKey(ctx context.Context, endpoint string) (publicKey, error) {
+	if key, ok := gh.publicKeys[endpoint]; ok {
+		return key, nil
+	}
+
+	var key publicKey
+	if err := gh.request(ctx, http.MethodGet, endpoint+"/public-key", nil, &key); err != nil {
+		return publicKey{}, err
+	}
+	gh.publicKeys[endpoint] = key
+	return key, nil
+}
+
+func (gh *restGithubClient) repositoryIDs(ctx context.Context, repositories []string) ([]int64, error) {
+	ids := make([]int64, 0, len(repositories))
+	for _, repository := range repositories {
+		var result struct {
+			ID int64 `json:"id"`
+		}
+		if err := gh.request(ctx, http.MethodGet, "repos/"+repository, nil, &result); err != nil {
+			return nil, fmt.Errorf("failed to look up repository %s: %w", repository, err)
+		}
+		ids = append(ids, result.ID)
+	}
+	return ids, nil
+}
+
+func (gh *restGithubClient) request(ctx context.Context, method string, path string, body any, result any) error {
+	var content io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		content = bytes.NewReader(encoded)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, gh.baseURL+"/"+path, content)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/vnd.github+json")
+	request.Header.Set("Authorization", "Bearer "+gh.token)
+	request.Header.Set("X-GitHub-Api-Version", apiVersion)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := gh.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s %s: %w", method, path, errNotFound)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		var githubError struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(responseBody, &githubError)
+		return fmt.Errorf("GitHub returned %s: %s", response.Status, githubError.Message)
+	}
+
+	if result != nil {
+		if err = json.Unmarshal(responseBody, result); err != nil {
+			return fmt.Errorf("unexpected response from GitHub: %w", err)
+		}
+	}
+	return nil
+}
+
+func (gh *restGithubClient) logPrefix() string {
+	if gh.dryRun {
+		return "DRY RUN: "
+	}
+	return ""
+}
+
+// encryptSecret seals the secret for the base64 encoded public key, the way
+// libsodium's crypto_box_seal does, and returns it base64 encoded.
+func encryptSecret(encodedKey string, secret string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil || len(decoded) != 32 {
+		return "", fmt.Errorf("invalid public key %q", encodedKey)
+	}
+
+	var key [32]byte
+	copy(key[:], decoded)
+	encrypted, err := box.SealAnonymous(nil, []byte(secret), &key, rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt the secret: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// resolveToken returns the token of GH_TOKEN, or the token gh is logged in
+// with.
+func resolveToken(ctx context.Context, lookupEnv func(key string) (string, bool), runner cli.CommandRunner) (string, error) {
+	if token, ok := lookupEnv(TokenEnv); ok && token != "" {
+		return token, nil
+	}
+
+	result, err := runner.Run(ctx, "gh", "auth", "token")
+	if err != nil {
+		return "", fmt.Errorf("either set %s or log in with gh auth login: %w", TokenEnv, err)
+	}
+	return strings.TrimSpace(string(result.Stdout)), nil
+}
+
+// Token returns the token for the REST API from GH_TOKEN, falling back to
+// gh auth token.
+func Token(ctx context.Context, timeout time.Duration) (string, error) {
+	return resolveToken(ctx, os.LookupEnv, cli.NewCommandRunner(timeout))
+}
+
+// NewRestClient returns a client calling the REST API of GitHub with the
+// token. Requests are stopped after the timeout.
+func NewRestClient(token string, dryRun bool, timeout time.Duration) GithubClient {
+	baseURL := defaultAPIURL
+	if apiURL, ok := os.LookupEnv(APIURLEnv); ok && apiURL != "" {
+		baseURL = apiURL
+	}
+
+	return &restGithubClient{
+		httpClient: &http.Client{Timeout: timeout},
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		token:      token,
+		dryRun:     dryRun,
+		publicKeys: make(map[string]publicKey),
+	}
+}
@@ -0,0 +1,406 @@
+package github
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/nacl/box"
+
+	"koenighotze.de/github-distribute-secrets/pkg/cli"
+)
+
+const (
+	testToken     = "test-token"
+	testKeyID     = "key-1"
+	testOwnerRepo = "test-org/test-repo"
+)
+
+// fakeGithub is a fake of the REST API. It decrypts uploaded secrets with its
+// private key, so tests can check what was actually sent.
+type fakeGithub struct {
+	server    *httptest.Server
+	requests  []string
+	secrets   map[string]string
+	bodies    map[string]map[string]any
+	variables map[string]string
+	deleted   []string
+}
+
+func createFakeGithub(t *testing.T) *fakeGithub {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeGithub{
+		secrets:   make(map[string]string),
+		bodies:    make(map[string]map[string]any),
+		variables: map[string]string{"EXISTING": "old"},
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.requests = append(fake.requests, r.Method+" "+r.URL.RequestURI())
+
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message":"Bad credentials"}`))
+			return
+		}
+
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		path := r.URL.Path
+
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(path, "/public-key") && !strings.Contains(path, "missing"):
+			_ = json.NewEncoder(w).Encode(map[string]string{"key_id": testKeyID, "key": base64.StdEncoding.EncodeToString(publicKey[:])})
+		case r.Method == http.MethodPut && strings.Contains(path, "/secrets/"):
+			if body["key_id"] != testKeyID {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"message":"unknown key"}`))
+				return
+			}
+			encrypted, _ := base64.StdEncoding.DecodeString(body["encrypted_value"].(string))
+			decrypted, ok := box.OpenAnonymous(nil, encrypted, publicKey, privateKey)
+			if !ok {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				_, _ = w.Write([]byte(`{"message":"cannot decrypt"}`))
+				return
+			}
+			fake.secrets[path] = string(decrypted)
+			fake.bodies[path] = body
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPatch && strings.HasPrefix(path, "/repos/"+testOwnerRepo+"/actions/variables/"):
+			name := strings.TrimPrefix(path, "/repos/"+testOwnerRepo+"/actions/variables/")
+			if _, ok := fake.variables[name]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+				return
+			}
+			fake.variables[name] = body["value"].(string)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPost && path == "/repos/"+testOwnerRepo+"/actions/variables":
+			fake.variables[body["name"].(string)] = body["value"].(string)
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodDelete && strings.HasPrefix(path, "/repos/"+testOwnerRepo+"/actions/secrets/"):
+			fake.deleted = append(fake.deleted, strings.TrimPrefix(path, "/repos/"+testOwnerRepo+"/actions/secrets/"))
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && path == "/repos/"+testOwnerRepo:
+			_, _ = w.Write([]byte(`{"id":3}`))
+		case r.Method == http.MethodGet && path == "/repos/test-org/a":
+			_, _ = w.Write([]byte(`{"id":1}`))
+		case r.Method == http.MethodGet && path == "/repos/test-org/b":
+			_, _ = w.Write([]byte(`{"id":2}`))
+		case r.Method == http.MethodGet && path == "/repos/"+testOwnerRepo+"/actions/secrets":
+			if r.URL.Query().Get("page") == "2" {
+				_, _ = w.Write([]byte(`{"total_count":2,"secrets":[{"name":"SECOND","updated_at":"2026-02-01T00:00:00Z"}]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"total_count":2,"secrets":[{"name":"FIRST","updated_at":"2026-01-01T00:00:00Z"}]}`))
+		case r.Method == http.MethodGet && path == "/orgs/test-org/repos":
+			_, _ = w.Write([]byte(`[{"full_name":"test-org/a","owner":{"login":"test-org"}},{"full_name":"test-org/b","owner":{"login":"test-org"}}]`))
+		case r.Method == http.MethodGet && path == "/user":
+			_, _ = w.Write([]byte(`{"login":"someone"}`))
+		case r.Method == http.MethodGet && path == "/users/other/repos":
+			_, _ = w.Write([]byte(`[{"full_name":"other/public","owner":{"login":"other"}}]`))
+		case r.Method == http.MethodGet && path == "/user/repos":
+			_, _ = w.Write([]byte(`[{"full_name":"someone/a","owner":{"login":"someone"}},{"full_name":"test-org/x","owner":{"login":"test-org"}}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+		}
+	}))
+	t.Cleanup(fake.server.Close)
+
+	return fake
+}
+
+func createRestClient(fake *fakeGithub, dryRun bool) *restGithubClient {
+	return &restGithubClient{
+		httpClient: fake.server.Client(),
+		baseURL:    fake.server.URL,
+		token:      testToken,
+		dryRun:     dryRun,
+		publicKeys: make(map[string]publicKey),
+	}
+}
+
+func TestNewRestClient(t *testing.T) {
+	t.Run("should use the public API by default", func(t *testing.T) {
+		t.Setenv(APIURLEnv, "")
+
+		result := NewRestClient(testToken, false, time.Minute)
+
+		client, ok := result.(*restGithubClient)
+		assert.True(t, ok, "Expected result to be of type restGithubClient")
+		assert.Equal(t, "https://api.github.com", client.baseURL)
+	})
+
+	t.Run("should take the API URL from the environment", func(t *testing.T) {
+		t.Setenv(APIURLEnv, "https://github.example.com/api/v3/")
+
+		result := NewRestClient(testToken, true, time.Minute)
+
+		assert.Equal(t, "https://github.example.com/api/v3", result.(*restGithubClient).baseURL)
+	})
+}
+
+func TestRestAddSecrets(t *testing.T) {
+	t.Run("should upload the encrypted secret to the repository", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Equal(t, testSecretValue, fake.secrets["/repos/test-org/test-repo/actions/secrets/TEST_KEY"])
+	})
+
+	t.Run("should read the public key once per repository", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		_ = client.AddSecretToRepository(context.Background(), "FIRST", testSecretValue, testOwnerRepo)
+		_ = client.AddSecretToRepository(context.Background(), "SECOND", testSecretValue, testOwnerRepo)
+
+		assert.Equal(t, []string{
+			"GET /repos/test-org/test-repo/actions/secrets/public-key",
+			"PUT /repos/test-org/test-repo/actions/secrets/FIRST",
+			"PUT /repos/test-org/test-repo/actions/secrets/SECOND",
+		}, fake.requests)
+	})
+
+	t.Run("should upload the secret of an app", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.AddSecretToApp(context.Background(), testSecretKey, testSecretValue, "dependabot", testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Equal(t, testSecretValue, fake.secrets["/repos/test-org/test-repo/dependabot/secrets/TEST_KEY"])
+	})
+
+	t.Run("should upload the secret of an environment", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.AddSecretToEnvironment(context.Background(), testSecretKey, testSecretValue, testEnvironment, testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Equal(t, testSecretValue, fake.secrets["/repos/test-org/test-repo/environments/production/secrets/TEST_KEY"])
+	})
+
+	t.Run("should upload the secret of an organization with the selected repositories", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.AddSecretToOrganization(context.Background(), testSecretKey, testSecretValue, testOrgName, "selected", []string{"test-org/a", "test-org/b"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, testSecretValue, fake.secrets["/orgs/test-org/actions/secrets/TEST_KEY"])
+		assert.Equal(t, "selected", fake.bodies["/orgs/test-org/actions/secrets/TEST_KEY"]["visibility"])
+		assert.Equal(t, []any{1.0, 2.0}, fake.bodies["/orgs/test-org/actions/secrets/TEST_KEY"]["selected_repository_ids"])
+	})
+
+	t.Run("should return an error if the public key cannot be read", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, "test-org/missing")
+
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorContains(t, err, "failed adding secret as key TEST_KEY to repository test-org/missing: failed to read the public key")
+	})
+
+	t.Run("should return the message of GitHub for rejected requests", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+		client.token = "wrong"
+
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, testOwnerRepo)
+
+		assert.ErrorContains(t, err, "GitHub returned 401 Unauthorized: Bad credentials")
+	})
+
+	t.Run("should only read the public key in dry run mode", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, true)
+
+		err := client.AddSecretToRepository(context.Background(), testSecretKey, testSecretValue, testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Empty(t, fake.secrets)
+		assert.Equal(t, []string{"GET /repos/test-org/test-repo/actions/secrets/public-key"}, fake.requests)
+	})
+}
+
+func TestRestAddVariableToRepository(t *testing.T) {
+	t.Run("should update an existing variable", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.AddVariableToRepository(context.Background(), "EXISTING", "new", testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "new", fake.variables["EXISTING"])
+	})
+
+	t.Run("should create a missing variable", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.AddVariableToRepository(context.Background(), "REGION", "europe-west3", testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "europe-west3", fake.variables["REGION"])
+	})
+}
+
+func TestRestDeleteSecret(t *testing.T) {
+	t.Run("should delete the secret of the repository", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		err := client.DeleteSecret(context.Background(), testSecretKey, testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{testSecretKey}, fake.deleted)
+	})
+
+	t.Run("should only check that the repository exists in dry run mode", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, true)
+
+		err := client.DeleteSecret(context.Background(), testSecretKey, testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Empty(t, fake.deleted)
+		assert.Equal(t, []string{"GET /repos/test-org/test-repo"}, fake.requests)
+	})
+
+	t.Run("should return an error for unknown repositories", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, true)
+
+		err := client.DeleteSecret(context.Background(), testSecretKey, "test-org/missing")
+
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorContains(t, err, "failed deleting secret with key TEST_KEY from repository test-org/missing")
+	})
+}
+
+func TestRestListRepositories(t *testing.T) {
+	t.Run("should list the repositories of an organization", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		result, err := client.ListRepositories(context.Background(), testOrgName)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"test-org/a", "test-org/b"}, result)
+	})
+
+	t.Run("should list the repositories of the authenticated user if the owner is no organization", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		result, err := client.ListRepositories(context.Background(), "someone")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"someone/a"}, result)
+	})
+
+	t.Run("should list the repositories of another user if the owner is no organization", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		result, err := client.ListRepositories(context.Background(), "other")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"other/public"}, result)
+	})
+
+	t.Run("should return an error if the owner does not exist", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		_, err := client.ListRepositories(context.Background(), "missing")
+
+		assert.ErrorIs(t, err, errNotFound)
+		assert.ErrorContains(t, err, "failed listing repositories of missing")
+	})
+}
+
+func TestRestListSecrets(t *testing.T) {
+	t.Run("should read every page of secrets", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		result, err := client.ListSecrets(context.Background(), testOwnerRepo)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Secret{
+			{Name: "FIRST", UpdatedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{Name: "SECOND", UpdatedAt: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		}, result)
+	})
+
+	t.Run("should return an error for unknown repositories", func(t *testing.T) {
+		fake := createFakeGithub(t)
+		client := createRestClient(fake, false)
+
+		_, err := client.ListSecrets(context.Background(), "test-org/missing")
+
+		assert.ErrorIs(t, err, errNotFound)
+	})
+}
+
+func TestEncryptSecret(t *testing.T) {
+	t.Run("should reject invalid public keys", func(t *testing.T) {
+		_, err := encryptSecret("not-a-key", testSecretValue)
+
+		assert.EqualError(t, err, `invalid public key "not-a-key"`)
+	})
+}
+
+func TestResolveToken(t *testing.T) {
+	t.Run("should prefer GH_TOKEN", func(t *testing.T) {
+		lookupEnv := func(key string) (string, bool) { return "from-env", key == TokenEnv }
+
+		result, err := resolveToken(context.Background(), lookupEnv, &cli.MockCommandRunner{T: t})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "from-env", result)
+	})
+
+	t.Run("should ask gh for the token", func(t *testing.T) {
+		runner := &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{Name: "gh", Args: []string{"auth", "token"}, Stdout: []byte("from-gh\n")},
+			T:               t,
+		}
+
+		result, err := resolveToken(context.Background(), func(string) (string, bool) { return "", false }, runner)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "from-gh", result)
+	})
+
+	t.Run("should return an error if gh is not logged in", func(t *testing.T) {
+		runner := &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{Name: "gh", Args: []string{"auth", "token"}, Error: assert.AnError},
+			T:               t,
+		}
+
+		_, err := resolveToken(context.Background(), func(string) (string, bool) { return "", false }, runner)
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "either set GH_TOKEN or log in with gh auth login")
+	})
+}
@@ -0,0 +1,83 @@
+package github
+
+import "context"
+
+// MockGithubClient records the calls that reach GitHub. The writes and
+// deletions are recorded in order in Writes, e.g. "owner/repo KEY=value".
+type MockGithubClient struct {
+	// Repositories and Secrets are listed by ListRepositories and ListSecrets.
+	Repositories []string
+	Secrets      map[string][]Secret
+
+	Writes                   []string
+	RepositoryCalls          int
+	AppCalls                 map[string]int
+	EnvironmentCalls         int
+	OrganizationCalls        int
+	OrganizationVisibility   string
+	OrganizationRepositories []string
+	VariableCalls            int
+	VariableValues           map[string]string
+	// Deleted holds the deleted secrets as repository/key.
+	Deleted []string
+
+	// Error is returned by every write, the other errors by the listings and
+	// deletions.
+	Error            error
+	ListError        error
+	ListSecretsError error
+	DeleteError      error
+}
+
+func (m *MockGithubClient) AddSecretToRepository(ctx context.Context, key string, secret string, repository string) (err error) {
+	m.RepositoryCalls++
+	m.Writes = append(m.Writes, repository+" "+key+"="+secret)
+	return m.Error
+}
+
+func (m *MockGithubClient) AddSecretToApp(ctx context.Context, key string, secret string, app string, repository string) (err error) {
+	if m.AppCalls == nil {
+		m.AppCalls = make(map[string]int)
+	}
+	m.AppCalls[app]++
+	m.Writes = append(m.Writes, repository+" "+app+" "+key+"="+secret)
+	return m.Error
+}
+
+func (m *MockGithubClient) AddSecretToEnvironment(ctx context.Context, key string, secret string, environment string, repository string) (err error) {
+	m.EnvironmentCalls++
+	m.Writes = append(m.Writes, repository+" "+environment+" "+key+"="+secret)
+	return m.Error
+}
+
+func (m *MockGithubClient) AddSecretToOrganization(ctx context.Context, key string, secret string, organization string, visibility string, repositories []string) (err error) {
+	m.OrganizationCalls++
+	m.OrganizationVisibility = visibility
+	m.OrganizationRepositories = repositories
+	m.Writes = append(m.Writes, organization+" "+key+"="+secret)
+	return m.Error
+}
+
+func (m *MockGithubClient) AddVariableToRepository(ctx context.Context, name string, value string, repository string) (err error) {
+	m.VariableCalls++
+	if m.VariableValues == nil {
+		m.VariableValues = make(map[string]string)
+	}
+	m.VariableValues[name] = value
+	m.Writes = append(m.Writes, repository+" variable "+name+"="+value)
+	return m.Error
+}
+
+func (m *MockGithubClient) ListRepositories(ctx context.Context, owner string) (repositories []string, err error) {
+	return m.Repositories, m.ListError
+}
+
+func (m *MockGithubClient) ListSecrets(ctx context.Context, repository string) (secrets []Secret, err error) {
+	return m.Secrets[repository], m.ListSecretsError
+}
+
+func (m *MockGithubClient) DeleteSecret(ctx context.Context, key string, repository string) (err error) {
+	m.Deleted = append(m.Deleted, repository+"/"+key)
+	m.Writes = append(m.Writes, "delete "+repository+" "+key)
+	return m.DeleteError
+}
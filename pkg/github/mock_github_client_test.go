@@ -0,0 +1,60 @@
+package github
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockGithubClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("should record the writes in order", func(t *testing.T) {
+		mock := &MockGithubClient{}
+
+		_ = mock.AddSecretToRepository(ctx, "KEY", "value", "owner/repo")
+		_ = mock.AddSecretToApp(ctx, "KEY", "value", "dependabot", "owner/repo")
+		_ = mock.AddSecretToEnvironment(ctx, "KEY", "value", "production", "owner/repo")
+		_ = mock.AddSecretToOrganization(ctx, "KEY", "value", "org", "selected", []string{"org/a"})
+		_ = mock.AddVariableToRepository(ctx, "NAME", "value", "owner/repo")
+		_ = mock.DeleteSecret(ctx, "OLD", "owner/repo")
+
+		assert.Equal(t, []string{
+			"owner/repo KEY=value",
+			"owner/repo dependabot KEY=value",
+			"owner/repo production KEY=value",
+			"org KEY=value",
+			"owner/repo variable NAME=value",
+			"delete owner/repo OLD",
+		}, mock.Writes)
+		assert.Equal(t, 1, mock.RepositoryCalls)
+		assert.Equal(t, map[string]int{"dependabot": 1}, mock.AppCalls)
+		assert.Equal(t, 1, mock.EnvironmentCalls)
+		assert.Equal(t, "selected", mock.OrganizationVisibility)
+		assert.Equal(t, []string{"org/a"}, mock.OrganizationRepositories)
+		assert.Equal(t, map[string]string{"NAME": "value"}, mock.VariableValues)
+		assert.Equal(t, []string{"owner/repo/OLD"}, mock.Deleted)
+	})
+
+	t.Run("should return the configured listings and errors", func(t *testing.T) {
+		secrets := []Secret{{Name: "KEY"}}
+		mock := &MockGithubClient{
+			Repositories:     []string{"owner/repo"},
+			Secrets:          map[string][]Secret{"owner/repo": secrets},
+			Error:            assert.AnError,
+			ListSecretsError: assert.AnError,
+		}
+
+		repositories, err := mock.ListRepositories(ctx, "owner")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"owner/repo"}, repositories)
+
+		listed, err := mock.ListSecrets(ctx, "owner/repo")
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Equal(t, secrets, listed)
+
+		assert.ErrorIs(t, mock.AddSecretToRepository(ctx, "KEY", "value", "owner/repo"), assert.AnError)
+		assert.NoError(t, mock.DeleteSecret(ctx, "KEY", "owner/repo"))
+	})
+}
@@ -1,65 +1,112 @@
 package onepassword
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
 	"koenighotze.de/github-distribute-secrets/pkg/cli"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
 )
 
-type cacheEntry struct {
-	Value string
-	Err   error
-}
-
-type secretCacheType map[string]cacheEntry
-
 type OnePasswordClient interface {
-	GetSecret(secretPath string) (secret string, err error)
+	GetSecret(ctx context.Context, secretPath string) (secret string, err error)
 }
 
 type cliClient struct {
 	runner cli.CommandRunner
+	// writeTemplate stores the template of a batch read in a file and returns
+	// its name and a function removing it again.
+	writeTemplate func(content []byte) (name string, remove func(), err error)
+	// newBoundary returns the marker separating the secrets of a batch read.
+	newBoundary func() string
 }
 
-func (d *cliClient) GetSecret(secretPath string) (secret string, err error) {
-	out, err := d.runner.Run("op", "read", secretPath)
+func (d *cliClient) GetSecret(ctx context.Context, secretPath string) (secret string, err error) {
+	result, err := d.runner.Run(ctx, "op", "read", secretPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read secret %s: %w", secretPath, err)
 	}
 
-	secret = strings.TrimSpace(string(out))
+	secret = strings.TrimSpace(string(result.Stdout))
 
 	return
 }
 
-type cachedClient struct {
-	Cache secretCacheType
-	Op    OnePasswordClient
-}
+// ReadAll resolves every secret with a single op inject call, so op asks for
+// authorization once. The template surrounds each reference with markers, as
+// secrets may span several lines.
+func (d *cliClient) ReadAll(ctx context.Context, secretPaths []string) (map[string]string, error) {
+	boundary := d.newBoundary()
+	marker := func(index int, kind string) string {
+		return fmt.Sprintf("<%s:%d:%s>", boundary, index, kind)
+	}
 
-func (c *cachedClient) GetSecret(secretPath string) (secret string, err error) {
-	if cachedSecret, exists := c.Cache[secretPath]; exists {
-		return cachedSecret.Value, cachedSecret.Err
+	var template strings.Builder
+	for index, secretPath := range secretPaths {
+		fmt.Fprintf(&template, "%s{{ %s }}%s\n", marker(index, "begin"), secretPath, marker(index, "end"))
 	}
 
-	secret, err = c.Op.GetSecret(secretPath)
-	if err == nil {
-		c.Cache[secretPath] = cacheEntry{secret, nil}
-	} else {
-		c.Cache[secretPath] = cacheEntry{"", err}
+	name, remove, err := d.writeTemplate([]byte(template.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write the template of the batch read: %w", err)
 	}
+	defer remove()
 
-	return
+	result, err := d.runner.Run(ctx, "op", "inject", "--in-file", name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %d secrets in one batch: %w", len(secretPaths), err)
+	}
+
+	secrets := make(map[string]string, len(secretPaths))
+	rest := string(result.Stdout)
+	for index, secretPath := range secretPaths {
+		_, afterBegin, foundBegin := strings.Cut(rest, marker(index, "begin"))
+		secret, afterEnd, foundEnd := strings.Cut(afterBegin, marker(index, "end"))
+		if !foundBegin || !foundEnd {
+			return nil, fmt.Errorf("output of the batch read lacks secret %s", secretPath)
+		}
+		secrets[secretPath] = strings.TrimSpace(secret)
+		rest = afterEnd
+	}
+
+	return secrets, nil
 }
 
-func NewClient() OnePasswordClient {
-	client := &cachedClient{
-		Cache: make(secretCacheType),
-		Op: &cliClient{
-			runner: cli.NewCommandRunner(),
-		},
+func writeTemporaryTemplate(content []byte) (name string, remove func(), err error) {
+	file, err := os.CreateTemp("", "github-distribute-secrets-*.tpl")
+	if err != nil {
+		return "", nil, err
+	}
+	remove = func() { _ = os.Remove(file.Name()) }
+
+	if _, err = file.Write(content); err != nil {
+		_ = file.Close()
+		remove()
+		return "", nil, err
 	}
+	if err = file.Close(); err != nil {
+		remove()
+		return "", nil, err
+	}
+
+	return file.Name(), remove, nil
+}
+
+func randomBoundary() string {
+	return rand.Text()
+}
 
-	return client
+// NewClient returns a caching client reading secrets with the op CLI. A
+// single call of op is stopped after the timeout. op may prompt on the
+// terminal to be authorized.
+func NewClient(timeout time.Duration) OnePasswordClient {
+	return provider.Cached(&cliClient{
+		runner:        cli.NewInteractiveCommandRunner(timeout),
+		writeTemplate: writeTemporaryTemplate,
+		newBoundary:   randomBoundary,
+	})
 }
@@ -1,12 +1,16 @@
 package onepassword
 
 import (
+	"context"
 	"errors"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"koenighotze.de/github-distribute-secrets/pkg/cli"
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
 )
 
 const (
@@ -18,7 +22,7 @@ func createMockOnePasswordCommandRunner(t *testing.T, output []byte, err error)
 		ExpectedCommand: cli.ExpectedCommand{
 			Name:   "op",
 			Args:   []string{"read", testSecretPath},
-			Output: output,
+			Stdout: output,
 			Error:  err,
 		},
 		T: t,
@@ -27,11 +31,13 @@ func createMockOnePasswordCommandRunner(t *testing.T, output []byte, err error)
 
 func TestNewClient(t *testing.T) {
 	t.Run("should return the caching client", func(t *testing.T) {
-		result := NewClient()
+		result := NewClient(time.Minute)
 
-		_, ok := result.(*cachedClient)
+		assert.Implements(t, (*provider.Prefetcher)(nil), result)
+	})
 
-		assert.True(t, ok, "Expected result to be of type cachedClient")
+	t.Run("should read secrets in batches", func(t *testing.T) {
+		assert.Implements(t, (*provider.BatchReader)(nil), &cliClient{})
 	})
 }
 
@@ -41,7 +47,7 @@ func TestGetSecret(t *testing.T) {
 			runner: createMockOnePasswordCommandRunner(t, []byte("supersecret\n"), nil),
 		}
 
-		result, err := client.GetSecret(testSecretPath)
+		result, err := client.GetSecret(context.Background(), testSecretPath)
 
 		assert.Nil(t, err)
 		assert.Equal(t, "supersecret", result)
@@ -52,7 +58,7 @@ func TestGetSecret(t *testing.T) {
 			runner: createMockOnePasswordCommandRunner(t, []byte("   supersecret   \n"), nil),
 		}
 
-		result, err := client.GetSecret(testSecretPath)
+		result, err := client.GetSecret(context.Background(), testSecretPath)
 
 		assert.Nil(t, err)
 		assert.Equal(t, "supersecret", result)
@@ -64,70 +70,183 @@ func TestGetSecret(t *testing.T) {
 			runner: createMockOnePasswordCommandRunner(t, nil, expectedError),
 		}
 
-		_, err := client.GetSecret(testSecretPath)
+		_, err := client.GetSecret(context.Background(), testSecretPath)
 
 		assert.ErrorContains(t, err, "bumm")
 		assert.ErrorIs(t, err, expectedError)
 	})
 
+	t.Run("should ignore what op prints to stderr", func(t *testing.T) {
+		client := cliClient{
+			runner: &cli.MockCommandRunner{
+				ExpectedCommand: cli.ExpectedCommand{
+					Name:   "op",
+					Args:   []string{"read", testSecretPath},
+					Stdout: []byte("supersecret\n"),
+					Stderr: []byte("A new version of op is available\n"),
+				},
+				T: t,
+			},
+		}
+
+		result, err := client.GetSecret(context.Background(), testSecretPath)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "supersecret", result)
+	})
+
+	t.Run("should include stderr of op in the error message", func(t *testing.T) {
+		client := cliClient{
+			runner: createMockOnePasswordCommandRunner(t, nil, &cli.ExitError{Name: "op", ExitCode: 1, Stderr: "[ERROR] isn't an item"}),
+		}
+
+		_, err := client.GetSecret(context.Background(), testSecretPath)
+
+		assert.EqualError(t, err, "failed to read secret somepath: op exited with code 1: [ERROR] isn't an item")
+	})
+
 	t.Run("should include the secret path in the error message", func(t *testing.T) {
 		client := cliClient{
 			runner: createMockOnePasswordCommandRunner(t, nil, errors.New("op failed")),
 		}
 
-		_, err := client.GetSecret(testSecretPath)
+		_, err := client.GetSecret(context.Background(), testSecretPath)
 
 		assert.ErrorContains(t, err, testSecretPath)
 	})
 }
 
 func TestGetSecretWithCache(t *testing.T) {
-	prepareClient := func(path string, output []byte, err error, cache *cacheEntry) cachedClient {
-		mockRunner := createMockOnePasswordCommandRunner(t, output, err)
-		cliClient := cachedClient{
-			Cache: make(secretCacheType),
-			Op: &cliClient{
-				runner: mockRunner,
-			},
-		}
-		if nil != cache {
-			cliClient.Cache[path] = *cache
-		}
-
-		return cliClient
+	prepareClient := func(output []byte, err error) (provider.Provider, *cli.MockCommandRunner) {
+		mockRunner := createMockOnePasswordCommandRunner(t, output, err).(*cli.MockCommandRunner)
+		return provider.Cached(&cliClient{runner: mockRunner}), mockRunner
 	}
 
 	t.Run("should return the uncached value if uncached", func(t *testing.T) {
-		cliClient := prepareClient(testSecretPath, []byte("UncachedOutput"), nil, nil)
+		client, _ := prepareClient([]byte("UncachedOutput"), nil)
 
-		result, _ := cliClient.GetSecret(testSecretPath)
+		result, _ := client.GetSecret(context.Background(), testSecretPath)
 
 		assert.Equal(t, "UncachedOutput", result)
 	})
 
 	t.Run("should return the cached value if cached", func(t *testing.T) {
-		cliClient := prepareClient(testSecretPath, []byte("UncachedOutput"), nil, &cacheEntry{Value: "cached", Err: nil})
+		client, mockRunner := prepareClient([]byte("cached"), nil)
+		_, _ = client.GetSecret(context.Background(), testSecretPath)
+		mockRunner.ExpectedCommand.Stdout = []byte("UncachedOutput")
 
-		result, _ := cliClient.GetSecret(testSecretPath)
+		result, _ := client.GetSecret(context.Background(), testSecretPath)
 
 		assert.Equal(t, "cached", result)
 	})
 
 	t.Run("should return the uncached error if uncached", func(t *testing.T) {
-		cliClient := prepareClient(testSecretPath, nil, assert.AnError, nil)
+		client, _ := prepareClient(nil, assert.AnError)
 
-		_, err := cliClient.GetSecret(testSecretPath)
+		_, err := client.GetSecret(context.Background(), testSecretPath)
 
 		assert.ErrorIs(t, err, assert.AnError)
 	})
 
 	t.Run("should return the cached error if an error occured", func(t *testing.T) {
 		expectedError := errors.New("cachederror")
-		cliClient := prepareClient(testSecretPath, nil, assert.AnError, &cacheEntry{Value: "cached", Err: expectedError})
+		client, mockRunner := prepareClient(nil, expectedError)
+		_, _ = client.GetSecret(context.Background(), testSecretPath)
+		mockRunner.ExpectedCommand.Error = assert.AnError
+
+		_, err := client.GetSecret(context.Background(), testSecretPath)
+
+		assert.ErrorIs(t, err, expectedError)
+	})
+}
+
+func createBatchClient(t *testing.T, output []byte, err error) (*cliClient, *string) {
+	var template string
+	client := &cliClient{
+		runner: &cli.MockCommandRunner{
+			ExpectedCommand: cli.ExpectedCommand{
+				Name:   "op",
+				Args:   []string{"inject", "--in-file", "template.tpl"},
+				Stdout: output,
+				Error:  err,
+			},
+			T: t,
+		},
+		writeTemplate: func(content []byte) (string, func(), error) {
+			template = string(content)
+			return "template.tpl", func() {}, nil
+		},
+		newBoundary: func() string { return "B" },
+	}
+	return client, &template
+}
+
+func TestReadAll(t *testing.T) {
+	t.Run("should read every secret with one op inject call", func(t *testing.T) {
+		client, template := createBatchClient(t, []byte("<B:0:begin>first<B:0:end>\n<B:1:begin>multi\nline\n<B:1:end>\n"), nil)
+
+		result, err := client.ReadAll(context.Background(), []string{"op://vault/item/first", "op://vault/item/second"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"op://vault/item/first": "first", "op://vault/item/second": "multi\nline"}, result)
+		assert.Equal(t, "<B:0:begin>{{ op://vault/item/first }}<B:0:end>\n<B:1:begin>{{ op://vault/item/second }}<B:1:end>\n", *template)
+	})
+
+	t.Run("should return the error of op inject", func(t *testing.T) {
+		client, _ := createBatchClient(t, nil, assert.AnError)
+
+		_, err := client.ReadAll(context.Background(), []string{"op://vault/item/first"})
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed to read 1 secrets in one batch")
+	})
+
+	t.Run("should return an error if a secret is missing in the output", func(t *testing.T) {
+		client, _ := createBatchClient(t, []byte("<B:0:begin>first<B:0:end>\n"), nil)
+
+		_, err := client.ReadAll(context.Background(), []string{"op://vault/item/first", "op://vault/item/second"})
+
+		assert.EqualError(t, err, "output of the batch read lacks secret op://vault/item/second")
+	})
+
+	t.Run("should return an error if the template cannot be written", func(t *testing.T) {
+		client := &cliClient{
+			writeTemplate: func(content []byte) (string, func(), error) {
+				return "", nil, assert.AnError
+			},
+			newBoundary: randomBoundary,
+		}
+
+		_, err := client.ReadAll(context.Background(), []string{"op://vault/item/first"})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
 
-		_, err := cliClient.GetSecret(testSecretPath)
+func TestWriteTemporaryTemplate(t *testing.T) {
+	t.Run("should write the template to a file that can be removed", func(t *testing.T) {
+		name, remove, err := writeTemporaryTemplate([]byte("{{ op://vault/item/field }}"))
+		assert.NoError(t, err)
 
-		assert.ErrorIs(t, expectedError, err)
+		content, err := os.ReadFile(name)
+		assert.NoError(t, err)
+		assert.Equal(t, "{{ op://vault/item/field }}", string(content))
+
+		remove()
+		_, err = os.Stat(name)
+		assert.True(t, os.IsNotExist(err))
 	})
+}
 
+func TestPrefetch(t *testing.T) {
+	t.Run("should read the secrets of one batch from the cache", func(t *testing.T) {
+		batch, _ := createBatchClient(t, []byte("<B:0:begin>first<B:0:end>\n"), nil)
+		client := provider.Cached(batch)
+
+		err := client.(provider.Prefetcher).Prefetch(context.Background(), []string{"op://vault/item/first"})
+		result, _ := client.GetSecret(context.Background(), "op://vault/item/first")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "first", result)
+	})
 }
@@ -0,0 +1,211 @@
+package onepassword
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
+)
+
+// Environment variables configuring the access to a 1Password Connect server.
+const (
+	ConnectHostEnv  = "OP_CONNECT_HOST"
+	ConnectTokenEnv = "OP_CONNECT_TOKEN"
+)
+
+// connectClient reads secrets from a 1Password Connect server instead of the
+// op CLI. Vault and item names are resolved to IDs, and items are fetched once
+// no matter how many of their fields are read.
+type connectClient struct {
+	host       string
+	token      string
+	httpClient *http.Client
+	vaultIDs   map[string]string
+	items      map[string]*connectItem
+}
+
+type connectVault struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type connectItem struct {
+	ID       string           `json:"id"`
+	Title    string           `json:"title"`
+	Sections []connectSection `json:"sections"`
+	Fields   []connectField   `json:"fields"`
+}
+
+type connectSection struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type connectField struct {
+	ID      string          `json:"id"`
+	Label   string          `json:"label"`
+	Value   string          `json:"value"`
+	Section *connectSection `json:"section"`
+}
+
+type connectReference struct {
+	vault   string
+	item    string
+	section string
+	field   string
+}
+
+func parseConnectReference(secretPath string) (reference connectReference, err error) {
+	path, query, _ := strings.Cut(strings.TrimPrefix(secretPath, "op://"), "?")
+	if query != "" {
+		return reference, fmt.Errorf("query %s of %s is not supported by 1Password Connect", query, secretPath)
+	}
+
+	parts := strings.Split(path, "/")
+	switch len(parts) {
+	case 3:
+		return connectReference{vault: parts[0], item: parts[1], field: parts[2]}, nil
+	case 4:
+		return connectReference{vault: parts[0], item: parts[1], section: parts[2], field: parts[3]}, nil
+	default:
+		return reference, fmt.Errorf("%s is not a reference of the form op://vault/item[/section]/field", secretPath)
+	}
+}
+
+func (c *connectClient) GetSecret(ctx context.Context, secretPath string) (secret string, err error) {
+	reference, err := parseConnectReference(secretPath)
+	if err != nil {
+		return "", err
+	}
+
+	vaultID, err := c.vaultID(ctx, reference.vault)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", secretPath, err)
+	}
+
+	item, err := c.item(ctx, vaultID, reference.item)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", secretPath, err)
+	}
+
+	for _, field := range item.Fields {
+		if field.ID != reference.field && field.Label != reference.field {
+			continue
+		}
+		if reference.section != "" && !item.fieldInSection(field, reference.section) {
+			continue
+		}
+		return field.Value, nil
+	}
+
+	return "", fmt.Errorf("failed to read secret %s: item %s has no field %s", secretPath, reference.item, reference.field)
+}
+
+func (item *connectItem) fieldInSection(field connectField, section string) bool {
+	if field.Section == nil {
+		return false
+	}
+	for _, itemSection := range item.Sections {
+		if itemSection.ID == field.Section.ID {
+			return itemSection.ID == section || itemSection.Label == section
+		}
+	}
+	return field.Section.ID == section
+}
+
+// vaultID resolves the name of a vault to its ID. Names that do not match a
+// vault are taken to be IDs already.
+func (c *connectClient) vaultID(ctx context.Context, vault string) (string, error) {
+	if id, ok := c.vaultIDs[vault]; ok {
+		return id, nil
+	}
+
+	var vaults []connectVault
+	if err := c.get(ctx, "/v1/vaults?filter="+url.QueryEscape(fmt.Sprintf("name eq %q", vault)), &vaults); err != nil {
+		return "", err
+	}
+
+	id := vault
+	if len(vaults) > 0 {
+		id = vaults[0].ID
+	}
+	c.vaultIDs[vault] = id
+	return id, nil
+}
+
+// item fetches an item by title or ID. Items are cached, so several fields of
+// one item cost a single lookup.
+func (c *connectClient) item(ctx context.Context, vaultID string, item string) (*connectItem, error) {
+	cacheKey := vaultID + "/" + item
+	if cached, ok := c.items[cacheKey]; ok {
+		return cached, nil
+	}
+
+	var items []connectItem
+	if err := c.get(ctx, "/v1/vaults/"+url.PathEscape(vaultID)+"/items?filter="+url.QueryEscape(fmt.Sprintf("title eq %q", item)), &items); err != nil {
+		return nil, err
+	}
+
+	itemID := item
+	if len(items) > 0 {
+		itemID = items[0].ID
+	}
+
+	result := &connectItem{}
+	if err := c.get(ctx, "/v1/vaults/"+url.PathEscape(vaultID)+"/items/"+url.PathEscape(itemID), result); err != nil {
+		return nil, err
+	}
+
+	c.items[cacheKey] = result
+	return result, nil
+}
+
+func (c *connectClient) get(ctx context.Context, path string, result any) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, c.host+path, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+c.token)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		var connectError struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &connectError)
+		return fmt.Errorf("1Password Connect returned %s: %s", response.Status, connectError.Message)
+	}
+
+	if err = json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("unexpected response from 1Password Connect: %w", err)
+	}
+	return nil
+}
+
+// NewConnectClient returns a caching client reading secrets from the
+// 1Password Connect server at host. Requests are stopped after the timeout.
+func NewConnectClient(host string, token string, timeout time.Duration) OnePasswordClient {
+	return provider.Cached(&connectClient{
+		host:       strings.TrimSuffix(host, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: timeout},
+		vaultIDs:   make(map[string]string),
+		items:      make(map[string]*connectItem),
+	})
+}
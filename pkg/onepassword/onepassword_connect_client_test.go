@@ -0,0 +1,174 @@
+package onepassword
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"koenighotze.de/github-distribute-secrets/pkg/provider"
+)
+
+const testConnectToken = "connect-token"
+
+func createFakeConnectServer(t *testing.T) (*httptest.Server, *[]string) {
+	requests := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+
+		if r.Header.Get("Authorization") != "Bearer "+testConnectToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"status":401,"message":"Invalid token signature"}`))
+			return
+		}
+
+		switch r.URL.RequestURI() {
+		case "/v1/vaults?filter=name+eq+%22kh-development%22":
+			_, _ = w.Write([]byte(`[{"id":"vault1","name":"kh-development"}]`))
+		case "/v1/vaults?filter=name+eq+%22vault1%22":
+			_, _ = w.Write([]byte(`[]`))
+		case "/v1/vaults/vault1/items?filter=title+eq+%22Docker%22":
+			_, _ = w.Write([]byte(`[{"id":"item1","title":"Docker"}]`))
+		case "/v1/vaults/vault1/items?filter=title+eq+%22item1%22":
+			_, _ = w.Write([]byte(`[]`))
+		case "/v1/vaults/vault1/items/item1":
+			_, _ = w.Write([]byte(`{
+				"id": "item1",
+				"title": "Docker",
+				"sections": [{"id": "section1", "label": "registry"}],
+				"fields": [
+					{"id": "username", "label": "username", "value": "docker-user"},
+					{"id": "password", "label": "password", "value": "docker-password"},
+					{"id": "field3", "label": "token", "value": "registry-token", "section": {"id": "section1"}}
+				]
+			}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"status":404,"message":"Item not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func createConnectClient(server *httptest.Server, token string) *connectClient {
+	return &connectClient{
+		host:       server.URL,
+		token:      token,
+		httpClient: server.Client(),
+		vaultIDs:   make(map[string]string),
+		items:      make(map[string]*connectItem),
+	}
+}
+
+func TestNewConnectClient(t *testing.T) {
+	t.Run("should return the caching client", func(t *testing.T) {
+		result := NewConnectClient("http://localhost:8080", testConnectToken, time.Minute)
+
+		assert.Implements(t, (*provider.Prefetcher)(nil), result)
+		assert.NotImplements(t, (*provider.BatchReader)(nil), &connectClient{})
+	})
+}
+
+func TestConnectClientGetSecret(t *testing.T) {
+	t.Run("should resolve vault and item names", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		result, err := client.GetSecret(context.Background(), "op://kh-development/Docker/password")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "docker-password", result)
+	})
+
+	t.Run("should accept vault and item IDs", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		result, err := client.GetSecret(context.Background(), "op://vault1/item1/username")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "docker-user", result)
+	})
+
+	t.Run("should read fields of sections", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		result, err := client.GetSecret(context.Background(), "op://kh-development/Docker/registry/token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "registry-token", result)
+	})
+
+	t.Run("should look up an item once for several fields", func(t *testing.T) {
+		server, requests := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		_, _ = client.GetSecret(context.Background(), "op://kh-development/Docker/username")
+		_, _ = client.GetSecret(context.Background(), "op://kh-development/Docker/password")
+
+		assert.Equal(t, []string{
+			"/v1/vaults?filter=name+eq+%22kh-development%22",
+			"/v1/vaults/vault1/items?filter=title+eq+%22Docker%22",
+			"/v1/vaults/vault1/items/item1",
+		}, *requests)
+	})
+
+	t.Run("should return an error if the field does not exist", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		_, err := client.GetSecret(context.Background(), "op://kh-development/Docker/otp")
+
+		assert.EqualError(t, err, "failed to read secret op://kh-development/Docker/otp: item Docker has no field otp")
+	})
+
+	t.Run("should return an error if the field is not in the section", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		_, err := client.GetSecret(context.Background(), "op://kh-development/Docker/registry/password")
+
+		assert.ErrorContains(t, err, "item Docker has no field password")
+	})
+
+	t.Run("should return an error if the item does not exist", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		_, err := client.GetSecret(context.Background(), "op://kh-development/Missing/password")
+
+		assert.EqualError(t, err, "failed to read secret op://kh-development/Missing/password: 1Password Connect returned 404 Not Found: Item not found")
+	})
+
+	t.Run("should return an error if the token is rejected", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, "wrong")
+
+		_, err := client.GetSecret(context.Background(), "op://kh-development/Docker/password")
+
+		assert.ErrorContains(t, err, "1Password Connect returned 401 Unauthorized: Invalid token signature")
+	})
+
+	t.Run("should return an error for queries", func(t *testing.T) {
+		server, _ := createFakeConnectServer(t)
+		client := createConnectClient(server, testConnectToken)
+
+		_, err := client.GetSecret(context.Background(), "op://kh-development/Docker/password?attribute=otp")
+
+		assert.EqualError(t, err, "query attribute=otp of op://kh-development/Docker/password?attribute=otp is not supported by 1Password Connect")
+	})
+
+	t.Run("should return an error for malformed references", func(t *testing.T) {
+		client := &connectClient{}
+
+		_, err := client.GetSecret(context.Background(), "op://kh-development/Docker")
+
+		assert.ErrorContains(t, err, "is not a reference of the form op://vault/item[/section]/field")
+	})
+}
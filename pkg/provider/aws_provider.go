@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+type secretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+type parameterStoreAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// awsProvider reads secrets from AWS Secrets Manager, aws-sm://secret-id, and
+// the SSM Parameter Store, aws-ssm:///parameter/name. A #key suffix extracts
+// a key of a secret holding a JSON object. The clients are created on first
+// use with the standard AWS credential chain, AWS_ENDPOINT_URL overrides the
+// endpoint.
+type awsProvider struct {
+	loadConfig     func(ctx context.Context) (aws.Config, error)
+	secretsManager secretsManagerAPI
+	parameterStore parameterStoreAPI
+}
+
+func newAwsProvider() *awsProvider {
+	return &awsProvider{
+		loadConfig: func(ctx context.Context) (aws.Config, error) {
+			return config.LoadDefaultConfig(ctx)
+		},
+	}
+}
+
+func (p *awsProvider) GetSecret(ctx context.Context, reference string) (secret string, err error) {
+	scheme := Scheme(reference)
+	name, key, _ := strings.Cut(strings.TrimPrefix(reference, referenceForms[scheme].prefix), "#")
+
+	if err = p.connect(ctx); err != nil {
+		return "", err
+	}
+
+	switch scheme {
+	case SchemeAwsSecretsManager:
+		secret, err = p.getSecretValue(ctx, name)
+	case SchemeAwsParameterStore:
+		secret, err = p.getParameter(ctx, name)
+	default:
+		err = fmt.Errorf("unsupported scheme %s", scheme)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", reference, err)
+	}
+
+	if key == "" {
+		return secret, nil
+	}
+	return extractJSONKey(reference, secret, key)
+}
+
+func (p *awsProvider) connect(ctx context.Context) error {
+	if p.secretsManager != nil && p.parameterStore != nil {
+		return nil
+	}
+
+	awsConfig, err := p.loadConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load the AWS configuration: %w", err)
+	}
+	p.secretsManager = secretsmanager.NewFromConfig(awsConfig)
+	p.parameterStore = ssm.NewFromConfig(awsConfig)
+	return nil
+}
+
+func (p *awsProvider) getSecretValue(ctx context.Context, secretID string) (string, error) {
+	output, err := p.secretsManager.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.SecretString != nil {
+		return *output.SecretString, nil
+	}
+	return string(output.SecretBinary), nil
+}
+
+func (p *awsProvider) getParameter(ctx context.Context, name string) (string, error) {
+	output, err := p.parameterStore.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if output.Parameter == nil || output.Parameter.Value == nil {
+		return "", fmt.Errorf("parameter %s has no value", name)
+	}
+	return *output.Parameter.Value, nil
+}
+
+// extractJSONKey returns a key of a secret holding a JSON object.
+func extractJSONKey(reference string, secret string, key string) (string, error) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", reference, err)
+	}
+
+	value, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", reference, key)
+	}
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode key %s of secret %s: %w", key, reference, err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func createFakeAws(t *testing.T) *httptest.Server {
+	secrets := map[string]string{
+		"website/deploy": `{"access_key_id":"AKIA","port":8080}`,
+		"plain":          "plain-secret",
+	}
+	parameters := map[string]string{
+		"/website/bucket": "website-bucket",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			SecretId string
+			Name     string
+		}
+		_ = json.NewDecoder(r.Body).Decode(&input)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "secretsmanager.GetSecretValue":
+			if secret, ok := secrets[input.SecretId]; ok {
+				_ = json.NewEncoder(w).Encode(map[string]string{"Name": input.SecretId, "SecretString": secret})
+				return
+			}
+		case "AmazonSSM.GetParameter":
+			if value, ok := parameters[input.Name]; ok {
+				_ = json.NewEncoder(w).Encode(map[string]any{"Parameter": map[string]string{"Name": input.Name, "Value": value}})
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"ResourceNotFoundException","message":"not found"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func createAwsProvider(server *httptest.Server) *awsProvider {
+	return &awsProvider{
+		loadConfig: func(ctx context.Context) (aws.Config, error) {
+			return aws.Config{
+				Region:       "eu-central-1",
+				Credentials:  credentials.NewStaticCredentialsProvider("id", "secret", ""),
+				BaseEndpoint: aws.String(server.URL),
+				HTTPClient:   server.Client(),
+			}, nil
+		},
+	}
+}
+
+func TestAwsProvider(t *testing.T) {
+	t.Run("should read a secret from Secrets Manager", func(t *testing.T) {
+		provider := createAwsProvider(createFakeAws(t))
+
+		result, err := provider.GetSecret(context.Background(), "aws-sm://plain")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "plain-secret", result)
+	})
+
+	t.Run("should extract a key of a structured secret", func(t *testing.T) {
+		provider := createAwsProvider(createFakeAws(t))
+
+		result, err := provider.GetSecret(context.Background(), "aws-sm://website/deploy#access_key_id")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "AKIA", result)
+	})
+
+	t.Run("should encode keys that are not strings as JSON", func(t *testing.T) {
+		provider := createAwsProvider(createFakeAws(t))
+
+		result, err := provider.GetSecret(context.Background(), "aws-sm://website/deploy#port")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "8080", result)
+	})
+
+	t.Run("should read a parameter from the Parameter Store", func(t *testing.T) {
+		provider := createAwsProvider(createFakeAws(t))
+
+		result, err := provider.GetSecret(context.Background(), "aws-ssm:///website/bucket")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "website-bucket", result)
+	})
+
+	t.Run("should return an error if the secret does not exist", func(t *testing.T) {
+		provider := createAwsProvider(createFakeAws(t))
+
+		_, err := provider.GetSecret(context.Background(), "aws-sm://missing")
+
+		assert.ErrorContains(t, err, "failed to read secret aws-sm://missing")
+		assert.ErrorContains(t, err, "ResourceNotFoundException")
+	})
+
+	t.Run("should return an error if the key does not exist", func(t *testing.T) {
+		provider := createAwsProvider(createFakeAws(t))
+
+		_, err := provider.GetSecret(context.Background(), "aws-sm://website/deploy#secret_access_key")
+
+		assert.EqualError(t, err, "secret aws-sm://website/deploy#secret_access_key has no key secret_access_key")
+	})
+
+	t.Run("should return an error if a key is extracted from a plain secret", func(t *testing.T) {
+		provider := createAwsProvider(createFakeAws(t))
+
+		_, err := provider.GetSecret(context.Background(), "aws-sm://plain#key")
+
+		assert.ErrorContains(t, err, "secret aws-sm://plain#key is not a JSON object")
+	})
+
+	t.Run("should return an error if the AWS configuration cannot be loaded", func(t *testing.T) {
+		provider := &awsProvider{loadConfig: func(ctx context.Context) (aws.Config, error) {
+			return aws.Config{}, assert.AnError
+		}}
+
+		_, err := provider.GetSecret(context.Background(), "aws-sm://plain")
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.ErrorContains(t, err, "failed to load the AWS configuration")
+	})
+}
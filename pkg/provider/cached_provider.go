@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"context"
+	"slices"
+)
+
+// BatchReader is implemented by providers that can read many references in a
+// single call, e.g. with one op inject call.
+type BatchReader interface {
+	ReadAll(ctx context.Context, references []string) (map[string]string, error)
+}
+
+type cacheEntry struct {
+	Value string
+	Err   error
+}
+
+// cachedProvider remembers the value or error of every reference, so each
+// reference is read at most once per run.
+type cachedProvider struct {
+	Cache    map[string]cacheEntry
+	Provider Provider
+}
+
+func (c *cachedProvider) GetSecret(ctx context.Context, reference string) (secret string, err error) {
+	if cachedSecret, exists := c.Cache[reference]; exists {
+		return cachedSecret.Value, cachedSecret.Err
+	}
+
+	secret, err = c.Provider.GetSecret(ctx, reference)
+	c.Cache[reference] = cacheEntry{secret, err}
+
+	return
+}
+
+// Prefetch reads the references not cached yet in one batch, if the provider
+// is a BatchReader. If the batch fails nothing is cached, so every reference
+// is read on its own later on and a broken reference shows up by name.
+func (c *cachedProvider) Prefetch(ctx context.Context, references []string) error {
+	batch, ok := c.Provider.(BatchReader)
+	if !ok {
+		return nil
+	}
+
+	missing := make([]string, 0, len(references))
+	for _, reference := range references {
+		if _, exists := c.Cache[reference]; !exists && !slices.Contains(missing, reference) {
+			missing = append(missing, reference)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	secrets, err := batch.ReadAll(ctx, missing)
+	if err != nil {
+		return err
+	}
+	for reference, secret := range secrets {
+		c.Cache[reference] = cacheEntry{secret, nil}
+	}
+
+	return nil
+}
+
+// Cached wraps a provider that is expensive to call with a per-run cache.
+func Cached(provider Provider) Provider {
+	return &cachedProvider{
+		Cache:    make(map[string]cacheEntry),
+		Provider: provider,
+	}
+}
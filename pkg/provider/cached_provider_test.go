@@ -0,0 +1,86 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachedProvider(t *testing.T) {
+	t.Run("should read each reference once", func(t *testing.T) {
+		wrapped := &recordingProvider{secret: "supersecret"}
+		provider := Cached(wrapped)
+
+		first, _ := provider.GetSecret(context.Background(), "vault://secret/app#token")
+		second, _ := provider.GetSecret(context.Background(), "vault://secret/app#token")
+
+		assert.Equal(t, "supersecret", first)
+		assert.Equal(t, "supersecret", second)
+		assert.Len(t, wrapped.references, 1)
+	})
+
+	t.Run("should cache errors", func(t *testing.T) {
+		wrapped := &recordingProvider{err: assert.AnError}
+		provider := Cached(wrapped)
+
+		_, _ = provider.GetSecret(context.Background(), "vault://secret/app#token")
+		_, err := provider.GetSecret(context.Background(), "vault://secret/app#token")
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Len(t, wrapped.references, 1)
+	})
+}
+
+// batchProvider reads references in batches.
+type batchProvider struct {
+	recordingProvider
+	batches [][]string
+	secrets map[string]string
+	err     error
+}
+
+func (p *batchProvider) ReadAll(ctx context.Context, references []string) (map[string]string, error) {
+	p.batches = append(p.batches, references)
+	return p.secrets, p.err
+}
+
+func TestCachedProviderPrefetch(t *testing.T) {
+	t.Run("should add the secrets read in one batch to the cache", func(t *testing.T) {
+		wrapped := &batchProvider{secrets: map[string]string{"op://vault/item/first": "first"}}
+		provider := Cached(wrapped).(*cachedProvider)
+		provider.Cache["op://vault/item/cached"] = cacheEntry{Value: "cached"}
+
+		err := provider.Prefetch(context.Background(), []string{"op://vault/item/first", "op://vault/item/cached", "op://vault/item/first"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{{"op://vault/item/first"}}, wrapped.batches)
+		assert.Equal(t, cacheEntry{Value: "first"}, provider.Cache["op://vault/item/first"])
+	})
+
+	t.Run("should not cache anything if the batch fails", func(t *testing.T) {
+		wrapped := &batchProvider{err: assert.AnError}
+		provider := Cached(wrapped).(*cachedProvider)
+
+		err := provider.Prefetch(context.Background(), []string{"op://vault/item/first"})
+
+		assert.ErrorIs(t, err, assert.AnError)
+		assert.Empty(t, provider.Cache)
+	})
+
+	t.Run("should not read anything if every secret is cached", func(t *testing.T) {
+		wrapped := &batchProvider{}
+		provider := Cached(wrapped).(*cachedProvider)
+		provider.Cache["op://vault/item/cached"] = cacheEntry{Value: "cached"}
+
+		assert.NoError(t, provider.Prefetch(context.Background(), []string{"op://vault/item/cached"}))
+		assert.Empty(t, wrapped.batches)
+	})
+
+	t.Run("should do nothing if the provider cannot read in batches", func(t *testing.T) {
+		provider := Cached(&recordingProvider{}).(*cachedProvider)
+
+		assert.NoError(t, provider.Prefetch(context.Background(), []string{"vault://secret/app#token"}))
+		assert.Empty(t, provider.Cache)
+	})
+}
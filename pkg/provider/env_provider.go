@@ -0,0 +1,21 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// envProvider reads secrets from environment variables, env://NAME.
+type envProvider struct {
+	lookupEnv func(key string) (string, bool)
+}
+
+func (p *envProvider) GetSecret(_ context.Context, reference string) (secret string, err error) {
+	name := strings.TrimPrefix(reference, referenceForms[SchemeEnv].prefix)
+	secret, found := p.lookupEnv(name)
+	if !found {
+		return "", fmt.Errorf("environment variable %s of %s is not set", name, reference)
+	}
+	return secret, nil
+}
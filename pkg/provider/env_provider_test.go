@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvProvider(t *testing.T) {
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := map[string]string{"TOKEN": "supersecret", "EMPTY": ""}[key]
+		return value, ok
+	}
+
+	t.Run("should return the value of the environment variable", func(t *testing.T) {
+		provider := &envProvider{lookupEnv: lookupEnv}
+
+		result, err := provider.GetSecret(context.Background(), "env://TOKEN")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "supersecret", result)
+	})
+
+	t.Run("should return empty variables", func(t *testing.T) {
+		provider := &envProvider{lookupEnv: lookupEnv}
+
+		result, err := provider.GetSecret(context.Background(), "env://EMPTY")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "", result)
+	})
+
+	t.Run("should return an error if the variable is not set", func(t *testing.T) {
+		provider := &envProvider{lookupEnv: lookupEnv}
+
+		_, err := provider.GetSecret(context.Background(), "env://MISSING")
+
+		assert.EqualError(t, err, "environment variable MISSING of env://MISSING is not set")
+	})
+}
@@ -0,0 +1,21 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// fileProvider reads secrets from files, file://path. Relative paths are
+// resolved against the working directory, surrounding whitespace is trimmed.
+type fileProvider struct {
+	readFile func(name string) ([]byte, error)
+}
+
+func (p *fileProvider) GetSecret(_ context.Context, reference string) (secret string, err error) {
+	content, err := p.readFile(strings.TrimPrefix(reference, referenceForms[SchemeFile].prefix))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", reference, err)
+	}
+	return strings.TrimSpace(string(content)), nil
+}
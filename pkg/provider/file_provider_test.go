@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileProvider(t *testing.T) {
+	t.Run("should return the trimmed content of the file", func(t *testing.T) {
+		var readPath string
+		provider := &fileProvider{readFile: func(name string) ([]byte, error) {
+			readPath = name
+			return []byte("  supersecret\n"), nil
+		}}
+
+		result, err := provider.GetSecret(context.Background(), "file:///run/secrets/token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "supersecret", result)
+		assert.Equal(t, "/run/secrets/token", readPath)
+	})
+
+	t.Run("should return the error if the file cannot be read", func(t *testing.T) {
+		readError := errors.New("no such file")
+		provider := &fileProvider{readFile: func(name string) ([]byte, error) {
+			return nil, readError
+		}}
+
+		_, err := provider.GetSecret(context.Background(), "file://token.txt")
+
+		assert.ErrorIs(t, err, readError)
+		assert.ErrorContains(t, err, "failed to read secret file://token.txt")
+	})
+}
@@ -0,0 +1,14 @@
+package provider
+
+import (
+	"context"
+	"strings"
+)
+
+// literalProvider returns the value written in the reference itself,
+// literal:value. It is meant for values that are not secret.
+type literalProvider struct{}
+
+func (literalProvider) GetSecret(_ context.Context, reference string) (secret string, err error) {
+	return strings.TrimPrefix(reference, referenceForms[SchemeLiteral].prefix), nil
+}
@@ -0,0 +1,17 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLiteralProvider(t *testing.T) {
+	t.Run("should return the value of the reference", func(t *testing.T) {
+		result, err := literalProvider{}.GetSecret(context.Background(), "literal:europe-west3")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "europe-west3", result)
+	})
+}
@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Schemes of the references understood by the registry.
+const (
+	SchemeOnePassword = "op"
+	SchemeEnv         = "env"
+	SchemeFile        = "file"
+	SchemeLiteral     = "literal"
+	SchemeVault       = "vault"
+
+	SchemeAwsSecretsManager = "aws-sm"
+	SchemeAwsParameterStore = "aws-ssm"
+)
+
+// Provider resolves a reference to the value of a secret.
+type Provider interface {
+	GetSecret(ctx context.Context, reference string) (secret string, err error)
+}
+
+// Prefetcher is implemented by providers that can read many references at
+// once, e.g. to avoid an authorization prompt per reference.
+type Prefetcher interface {
+	Prefetch(ctx context.Context, references []string) error
+}
+
+type referenceForm struct {
+	prefix  string
+	pattern *regexp.Regexp
+	example string
+}
+
+// referenceForms describes the references of every known scheme, so they can
+// be checked when the configuration is loaded instead of when they are read.
+var referenceForms = map[string]referenceForm{
+	SchemeOnePassword: {"op://", regexp.MustCompile(`^op://[^/]+/[^/]+(/[^/]+)?/[^/?]+(\?.*)?$`), "op://vault/item[/section]/field"},
+	SchemeEnv:         {"env://", regexp.MustCompile(`^env://.+$`), "env://NAME"},
+	SchemeFile:        {"file://", regexp.MustCompile(`^file://.+$`), "file://path"},
+	SchemeLiteral:     {"literal:", regexp.MustCompile(`^literal:`), "literal:value"},
+	SchemeVault:       {"vault://", regexp.MustCompile(`^vault://[^/#]+/[^#]+#.+$`), "vault://mount/path#field"},
+
+	SchemeAwsSecretsManager: {"aws-sm://", regexp.MustCompile(`^aws-sm://[^#]+(#.+)?$`), "aws-sm://secret-id[#key]"},
+	SchemeAwsParameterStore: {"aws-ssm://", regexp.MustCompile(`^aws-ssm://[^#]+(#.+)?$`), "aws-ssm://parameter-name[#key]"},
+}
+
+// Scheme returns the scheme of a reference, e.g. op for op://vault/item/field.
+func Scheme(reference string) string {
+	scheme, _, found := strings.Cut(reference, ":")
+	if !found {
+		return ""
+	}
+	return scheme
+}
+
+// IsReference reports whether the value is a reference of a known scheme
+// rather than a plain value.
+func IsReference(value string) bool {
+	form, ok := referenceForms[Scheme(value)]
+	return ok && strings.HasPrefix(value, form.prefix)
+}
+
+// ValidateReference checks that the reference has a known scheme and the
+// form that scheme expects. The error describes the problem without
+// repeating the reference, so callers can say where it was found.
+func ValidateReference(reference string) error {
+	form, ok := referenceForms[Scheme(reference)]
+	if !ok {
+		prefixes := make([]string, 0, len(referenceForms))
+		for _, scheme := range slices.Sorted(maps.Keys(referenceForms)) {
+			prefixes = append(prefixes, referenceForms[scheme].prefix)
+		}
+		return fmt.Errorf("has an unknown scheme, expected one of %s", strings.Join(prefixes, ", "))
+	}
+	if !form.pattern.MatchString(reference) {
+		return fmt.Errorf("is not a reference of the form %s", form.example)
+	}
+	return nil
+}
+
+// Registry resolves references with the provider registered for their
+// scheme.
+type Registry struct {
+	providers map[string]Provider
+}
+
+func (r *Registry) Register(scheme string, provider Provider) {
+	r.providers[scheme] = provider
+}
+
+func (r *Registry) GetSecret(ctx context.Context, reference string) (secret string, err error) {
+	provider, ok := r.providers[Scheme(reference)]
+	if !ok {
+		return "", fmt.Errorf("no provider for reference %s", reference)
+	}
+	return provider.GetSecret(ctx, reference)
+}
+
+// Prefetch hands the references to the providers of their schemes that
+// support reading them at once. References that were not prefetched are read
+// one by one later on.
+func (r *Registry) Prefetch(ctx context.Context, references []string) error {
+	referencesByScheme := make(map[string][]string)
+	for _, reference := range references {
+		scheme := Scheme(reference)
+		referencesByScheme[scheme] = append(referencesByScheme[scheme], reference)
+	}
+
+	var errs []error
+	for _, scheme := range slices.Sorted(maps.Keys(referencesByScheme)) {
+		if prefetcher, ok := r.providers[scheme].(Prefetcher); ok {
+			if err := prefetcher.Prefetch(ctx, referencesByScheme[scheme]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewRegistry returns a registry of the built-in providers. References to
+// 1Password are resolved by the given client.
+func NewRegistry(onePassword Provider) *Registry {
+	registry := &Registry{providers: make(map[string]Provider)}
+	registry.Register(SchemeOnePassword, onePassword)
+	registry.Register(SchemeEnv, &envProvider{lookupEnv: os.LookupEnv})
+	registry.Register(SchemeFile, &fileProvider{readFile: os.ReadFile})
+	registry.Register(SchemeLiteral, literalProvider{})
+	registry.Register(SchemeVault, Cached(newVaultProvider(os.LookupEnv, &http.Client{Timeout: vaultTimeout})))
+
+	aws := Cached(newAwsProvider())
+	registry.Register(SchemeAwsSecretsManager, aws)
+	registry.Register(SchemeAwsParameterStore, aws)
+	return registry
+}
@@ -0,0 +1,161 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingProvider struct {
+	references []string
+	secret     string
+	err        error
+}
+
+type prefetchingProvider struct {
+	recordingProvider
+	prefetched  []string
+	prefetchErr error
+}
+
+func (p *prefetchingProvider) Prefetch(ctx context.Context, references []string) error {
+	p.prefetched = append(p.prefetched, references...)
+	return p.prefetchErr
+}
+
+func (p *recordingProvider) GetSecret(ctx context.Context, reference string) (string, error) {
+	p.references = append(p.references, reference)
+	return p.secret, p.err
+}
+
+func TestScheme(t *testing.T) {
+	t.Run("should return the scheme of a reference", func(t *testing.T) {
+		assert.Equal(t, "op", Scheme("op://vault/item/field"))
+		assert.Equal(t, "literal", Scheme("literal:value"))
+	})
+
+	t.Run("should return nothing for values without a scheme", func(t *testing.T) {
+		assert.Equal(t, "", Scheme("europe-west3"))
+	})
+}
+
+func TestIsReference(t *testing.T) {
+	t.Run("should accept references of known schemes", func(t *testing.T) {
+		assert.True(t, IsReference("op://vault/item/field"))
+		assert.True(t, IsReference("env://TOKEN"))
+		assert.True(t, IsReference("file:///run/secrets/token"))
+		assert.True(t, IsReference("literal:value"))
+	})
+
+	t.Run("should reject plain values", func(t *testing.T) {
+		assert.False(t, IsReference("europe-west3"))
+		assert.False(t, IsReference("https://example.com"))
+		assert.False(t, IsReference("env:TOKEN"))
+	})
+}
+
+func TestValidateReference(t *testing.T) {
+	t.Run("should accept well formed references", func(t *testing.T) {
+		for _, reference := range []string{"op://vault/item/section/field", "env://TOKEN", "file://token.txt", "literal:"} {
+			assert.NoError(t, ValidateReference(reference), reference)
+		}
+	})
+
+	t.Run("should reject unknown schemes", func(t *testing.T) {
+		err := ValidateReference("vault/item/field")
+
+		assert.EqualError(t, err, "has an unknown scheme, expected one of aws-sm://, aws-ssm://, env://, file://, literal:, op://, vault://")
+	})
+
+	t.Run("should reject malformed references", func(t *testing.T) {
+		err := ValidateReference("op://vault/item")
+
+		assert.EqualError(t, err, "is not a reference of the form op://vault/item[/section]/field")
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("should resolve references with the provider of their scheme", func(t *testing.T) {
+		onePassword := &recordingProvider{secret: "supersecret"}
+		registry := NewRegistry(onePassword)
+
+		result, err := registry.GetSecret(context.Background(), "op://vault/item/field")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "supersecret", result)
+		assert.Equal(t, []string{"op://vault/item/field"}, onePassword.references)
+	})
+
+	t.Run("should give up on Vault requests after a timeout", func(t *testing.T) {
+		registry := NewRegistry(&recordingProvider{})
+
+		vault := registry.providers[SchemeVault].(*cachedProvider).Provider.(*vaultProvider)
+
+		assert.Equal(t, vaultTimeout, vault.httpClient.Timeout)
+	})
+
+	t.Run("should resolve literal references without 1Password", func(t *testing.T) {
+		onePassword := &recordingProvider{}
+		registry := NewRegistry(onePassword)
+
+		result, err := registry.GetSecret(context.Background(), "literal:value")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "value", result)
+		assert.Empty(t, onePassword.references)
+	})
+
+	t.Run("should use registered providers", func(t *testing.T) {
+		registry := NewRegistry(&recordingProvider{})
+		registry.Register("custom", &recordingProvider{secret: "custom-secret"})
+
+		result, err := registry.GetSecret(context.Background(), "custom://path")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "custom-secret", result)
+	})
+
+	t.Run("should return the error of the provider", func(t *testing.T) {
+		registry := NewRegistry(&recordingProvider{err: errors.New("op failed")})
+
+		_, err := registry.GetSecret(context.Background(), "op://vault/item/field")
+
+		assert.EqualError(t, err, "op failed")
+	})
+
+	t.Run("should return an error for references without a provider", func(t *testing.T) {
+		registry := NewRegistry(&recordingProvider{})
+
+		_, err := registry.GetSecret(context.Background(), "s3://bucket/key")
+
+		assert.EqualError(t, err, "no provider for reference s3://bucket/key")
+	})
+}
+
+func TestRegistryPrefetch(t *testing.T) {
+	t.Run("should hand the references to the providers of their scheme", func(t *testing.T) {
+		onePassword := &prefetchingProvider{}
+		registry := NewRegistry(onePassword)
+
+		err := registry.Prefetch(context.Background(), []string{"op://vault/item/a", "env://TOKEN", "op://vault/item/b"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"op://vault/item/a", "op://vault/item/b"}, onePassword.prefetched)
+	})
+
+	t.Run("should ignore providers that cannot prefetch", func(t *testing.T) {
+		registry := NewRegistry(&recordingProvider{})
+
+		assert.NoError(t, registry.Prefetch(context.Background(), []string{"op://vault/item/a", "unknown://x"}))
+	})
+
+	t.Run("should return the errors of the providers", func(t *testing.T) {
+		registry := NewRegistry(&prefetchingProvider{prefetchErr: assert.AnError})
+
+		err := registry.Prefetch(context.Background(), []string{"op://vault/item/a"})
+
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
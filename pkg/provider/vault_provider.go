@@ -0,0 +1,227 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Environment variables configuring the access to Vault. A token is used if
+// set, otherwise the provider logs in with AppRole.
+const (
+	vaultAddressEnv     = "VAULT_ADDR"
+	vaultTokenEnv       = "VAULT_TOKEN"
+	vaultRoleIDEnv      = "VAULT_ROLE_ID"
+	vaultSecretIDEnv    = "VAULT_SECRET_ID"
+	vaultAppRoleMount   = "VAULT_APPROLE_MOUNT"
+	defaultAppRoleMount = "approle"
+)
+
+// vaultTimeout limits a single request to Vault, so a server that stops
+// responding does not hang the run.
+const vaultTimeout = time.Minute
+
+// vaultProvider reads fields of KV secrets from HashiCorp Vault,
+// vault://mount/path#field. Both versions of the KV engine are supported,
+// the version of a mount is looked up on first use.
+type vaultProvider struct {
+	lookupEnv  func(key string) (string, bool)
+	httpClient *http.Client
+	address    string
+	token      string
+	// mounts maps a path to its KV mount, e.g. secret/ with version 2.
+	mounts map[string]vaultMount
+}
+
+type vaultMount struct {
+	path    string
+	version string
+}
+
+type vaultResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Auth   *vaultAuth      `json:"auth"`
+	Errors []string        `json:"errors"`
+}
+
+type vaultAuth struct {
+	ClientToken string `json:"client_token"`
+}
+
+func newVaultProvider(lookupEnv func(key string) (string, bool), httpClient *http.Client) *vaultProvider {
+	return &vaultProvider{
+		lookupEnv:  lookupEnv,
+		httpClient: httpClient,
+		mounts:     make(map[string]vaultMount),
+	}
+}
+
+func (p *vaultProvider) GetSecret(ctx context.Context, reference string) (secret string, err error) {
+	location, field, found := strings.Cut(strings.TrimPrefix(reference, referenceForms[SchemeVault].prefix), "#")
+	if !found || field == "" {
+		return "", fmt.Errorf("reference %s does not name a field", reference)
+	}
+
+	if err = p.login(ctx); err != nil {
+		return "", err
+	}
+
+	mount, err := p.mountOf(ctx, location)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the mount of %s: %w", reference, err)
+	}
+
+	fields, err := p.readFields(ctx, mount, strings.TrimPrefix(location, mount.path))
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", reference, err)
+	}
+
+	value, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %s", reference, field)
+	}
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode field %s of secret %s: %w", field, reference, err)
+	}
+	return string(encoded), nil
+}
+
+// login authenticates once per run, either with the configured token or by
+// logging in with AppRole.
+func (p *vaultProvider) login(ctx context.Context) error {
+	if p.token != "" {
+		return nil
+	}
+
+	address, ok := p.lookupEnv(vaultAddressEnv)
+	if !ok || address == "" {
+		return fmt.Errorf("%s must be set to read secrets from Vault", vaultAddressEnv)
+	}
+	p.address = strings.TrimSuffix(address, "/")
+
+	if token, ok := p.lookupEnv(vaultTokenEnv); ok && token != "" {
+		p.token = token
+		return nil
+	}
+
+	roleID, hasRoleID := p.lookupEnv(vaultRoleIDEnv)
+	secretID, hasSecretID := p.lookupEnv(vaultSecretIDEnv)
+	if !hasRoleID || !hasSecretID {
+		return fmt.Errorf("either %s or %s and %s must be set to read secrets from Vault", vaultTokenEnv, vaultRoleIDEnv, vaultSecretIDEnv)
+	}
+
+	mount, ok := p.lookupEnv(vaultAppRoleMount)
+	if !ok || mount == "" {
+		mount = defaultAppRoleMount
+	}
+
+	body, _ := json.Marshal(map[string]string{"role_id": roleID, "secret_id": secretID})
+	response, err := p.request(ctx, http.MethodPost, "auth/"+mount+"/login", body)
+	if err != nil {
+		return fmt.Errorf("failed to log in to Vault with AppRole: %w", err)
+	}
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return fmt.Errorf("failed to log in to Vault with AppRole: no token returned")
+	}
+
+	p.token = response.Auth.ClientToken
+	return nil
+}
+
+func (p *vaultProvider) mountOf(ctx context.Context, location string) (vaultMount, error) {
+	if mount, ok := p.mounts[location]; ok {
+		return mount, nil
+	}
+
+	response, err := p.request(ctx, http.MethodGet, "sys/internal/ui/mounts/"+location, nil)
+	if err != nil {
+		return vaultMount{}, err
+	}
+
+	var data struct {
+		Path    string `json:"path"`
+		Type    string `json:"type"`
+		Options struct {
+			Version string `json:"version"`
+		} `json:"options"`
+	}
+	if err = json.Unmarshal(response.Data, &data); err != nil {
+		return vaultMount{}, fmt.Errorf("unexpected response: %w", err)
+	}
+	if data.Type != "kv" {
+		return vaultMount{}, fmt.Errorf("%s is a %s mount, expected kv", data.Path, data.Type)
+	}
+
+	mount := vaultMount{path: data.Path, version: data.Options.Version}
+	p.mounts[location] = mount
+	return mount, nil
+}
+
+func (p *vaultProvider) readFields(ctx context.Context, mount vaultMount, path string) (map[string]any, error) {
+	if mount.version == "2" {
+		response, err := p.request(ctx, http.MethodGet, mount.path+"data/"+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		var data struct {
+			Data map[string]any `json:"data"`
+		}
+		if err = json.Unmarshal(response.Data, &data); err != nil {
+			return nil, fmt.Errorf("unexpected response: %w", err)
+		}
+		return data.Data, nil
+	}
+
+	response, err := p.request(ctx, http.MethodGet, mount.path+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	if err = json.Unmarshal(response.Data, &data); err != nil {
+		return nil, fmt.Errorf("unexpected response: %w", err)
+	}
+	return data, nil
+}
+
+func (p *vaultProvider) request(ctx context.Context, method string, path string, body []byte) (*vaultResponse, error) {
+	request, err := http.NewRequestWithContext(ctx, method, p.address+"/v1/"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		request.Header.Set("X-Vault-Token", p.token)
+	}
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result vaultResponse
+	if len(content) > 0 {
+		if err = json.Unmarshal(content, &result); err != nil {
+			return nil, fmt.Errorf("unexpected response with status %s: %w", response.Status, err)
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returned %s: %s", response.Status, strings.Join(result.Errors, ", "))
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testVaultToken = "test-token"
+
+func createFakeVault(t *testing.T) (*httptest.Server, *[]string) {
+	requests := make([]string, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var credentials map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&credentials)
+			if credentials["role_id"] != "role" || credentials["secret_id"] != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"auth":{"client_token":"` + testVaultToken + `"}}`))
+			return
+		}
+
+		if r.Header.Get("X-Vault-Token") != testVaultToken {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/secret/"):
+			_, _ = w.Write([]byte(`{"data":{"path":"secret/","type":"kv","options":{"version":"2"}}}`))
+		case strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/kv/"):
+			_, _ = w.Write([]byte(`{"data":{"path":"kv/","type":"kv","options":null}}`))
+		case strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/pki/"):
+			_, _ = w.Write([]byte(`{"data":{"path":"pki/","type":"pki","options":null}}`))
+		case r.URL.Path == "/v1/secret/data/team/app":
+			_, _ = w.Write([]byte(`{"data":{"data":{"token":"v2-secret","port":8080,"settings":{"region":"eu"}},"metadata":{"version":3}}}`))
+		case r.URL.Path == "/v1/kv/app":
+			_, _ = w.Write([]byte(`{"data":{"token":"v1-secret"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func createVaultEnv(variables map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := variables[key]
+		return value, ok
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	t.Run("should read a field of a KV v2 secret", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+
+		result, err := provider.GetSecret(context.Background(), "vault://secret/team/app#token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "v2-secret", result)
+	})
+
+	t.Run("should read a field of a KV v1 secret", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+
+		result, err := provider.GetSecret(context.Background(), "vault://kv/app#token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "v1-secret", result)
+	})
+
+	t.Run("should format fields that are not strings", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+
+		result, err := provider.GetSecret(context.Background(), "vault://secret/team/app#port")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "8080", result)
+	})
+
+	t.Run("should encode fields holding objects as JSON", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+
+		result, err := provider.GetSecret(context.Background(), "vault://secret/team/app#settings")
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"region":"eu"}`, result)
+	})
+
+	t.Run("should log in with AppRole once", func(t *testing.T) {
+		server, requests := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultRoleIDEnv: "role", vaultSecretIDEnv: "secret"}), server.Client())
+
+		_, err := provider.GetSecret(context.Background(), "vault://secret/team/app#token")
+		assert.NoError(t, err)
+		_, err = provider.GetSecret(context.Background(), "vault://secret/team/app#port")
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"POST /v1/auth/approle/login",
+			"GET /v1/sys/internal/ui/mounts/secret/team/app",
+			"GET /v1/secret/data/team/app",
+			"GET /v1/secret/data/team/app",
+		}, *requests)
+	})
+
+	t.Run("should return an error if the AppRole login fails", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultRoleIDEnv: "role", vaultSecretIDEnv: "wrong"}), server.Client())
+
+		_, err := provider.GetSecret(context.Background(), "vault://secret/team/app#token")
+
+		assert.ErrorContains(t, err, "failed to log in to Vault with AppRole: vault returned 400 Bad Request: invalid role or secret ID")
+	})
+
+	t.Run("should return an error if Vault is not configured", func(t *testing.T) {
+		provider := newVaultProvider(createVaultEnv(map[string]string{}), http.DefaultClient)
+
+		_, err := provider.GetSecret(context.Background(), "vault://secret/team/app#token")
+
+		assert.EqualError(t, err, "VAULT_ADDR must be set to read secrets from Vault")
+	})
+
+	t.Run("should return an error if no credentials are configured", func(t *testing.T) {
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: "http://localhost:8200"}), http.DefaultClient)
+
+		_, err := provider.GetSecret(context.Background(), "vault://secret/team/app#token")
+
+		assert.EqualError(t, err, "either VAULT_TOKEN or VAULT_ROLE_ID and VAULT_SECRET_ID must be set to read secrets from Vault")
+	})
+
+	t.Run("should return an error if the field does not exist", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+
+		_, err := provider.GetSecret(context.Background(), "vault://secret/team/app#password")
+
+		assert.EqualError(t, err, "secret vault://secret/team/app#password has no field password")
+	})
+
+	t.Run("should return an error if the secret does not exist", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+
+		_, err := provider.GetSecret(context.Background(), "vault://secret/team/missing#token")
+
+		assert.ErrorContains(t, err, "failed to read secret vault://secret/team/missing#token: vault returned 404 Not Found")
+	})
+
+	t.Run("should return an error if the token is rejected", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: "wrong"}), server.Client())
+
+		_, err := provider.GetSecret(context.Background(), "vault://secret/team/app#token")
+
+		assert.ErrorContains(t, err, "vault returned 403 Forbidden: permission denied")
+	})
+
+	t.Run("should return an error if the mount is not a KV engine", func(t *testing.T) {
+		server, _ := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+
+		_, err := provider.GetSecret(context.Background(), "vault://pki/issue#certificate")
+
+		assert.ErrorContains(t, err, "pki/ is a pki mount, expected kv")
+	})
+
+	t.Run("should not send requests when the context is cancelled", func(t *testing.T) {
+		server, requests := createFakeVault(t)
+		provider := newVaultProvider(createVaultEnv(map[string]string{vaultAddressEnv: server.URL, vaultTokenEnv: testVaultToken}), server.Client())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := provider.GetSecret(ctx, "vault://secret/team/app#token")
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Empty(t, *requests)
+	})
+}